@@ -0,0 +1,391 @@
+// Package audit records the decisions kubectl-guard makes on protected
+// contexts as JSON lines, so what was confirmed, aborted or blocked can be
+// reconstructed later.
+package audit
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cameronlockhart/kubectl-guard/config"
+	"github.com/cameronlockhart/kubectl-guard/guard"
+)
+
+// Defaults for an audit config that leaves fields unset.
+const (
+	defaultDir       = ".kubectl-guard"
+	defaultFile      = "audit.jsonl"
+	defaultMaxSizeMB = 10
+	defaultMaxFiles  = 5
+)
+
+// Outcome is what became of a command after the decision.
+type Outcome string
+
+const (
+	// OutcomeAllowed commands ran without a prompt because their mode is allow.
+	OutcomeAllowed Outcome = "allowed"
+	// OutcomeWarned commands ran after a warning banner.
+	OutcomeWarned Outcome = "warned"
+	// OutcomeConfirmed commands ran after the user confirmed them.
+	OutcomeConfirmed Outcome = "confirmed"
+	// OutcomeApproved commands ran without a prompt, approved by
+	// --guard-yes, KUBECTL_GUARD_ASSUME_YES or the non-interactive policy.
+	OutcomeApproved Outcome = "approved"
+	// OutcomeAborted commands were declined at the prompt.
+	OutcomeAborted Outcome = "aborted"
+	// OutcomeBlocked commands were refused by their mode.
+	OutcomeBlocked Outcome = "blocked"
+	// OutcomeDenied commands were refused by the non-interactive policy.
+	OutcomeDenied Outcome = "denied"
+	// OutcomeNoTerminal commands needed a confirmation nobody could give.
+	OutcomeNoTerminal Outcome = "no-terminal"
+)
+
+// Record is one line of the audit log.
+type Record struct {
+	ID   string    `json:"id"`
+	Time time.Time `json:"time"`
+	// User, Host and TTY identify who ran the command, and from where.
+	User string `json:"user"`
+	Host string `json:"host"`
+	TTY  string `json:"tty,omitempty"`
+
+	Context       string `json:"context,omitempty"`
+	Cluster       string `json:"cluster,omitempty"`
+	Server        string `json:"server,omitempty"`
+	Namespace     string `json:"namespace,omitempty"`
+	AllNamespaces bool   `json:"all_namespaces,omitempty"`
+
+	// Args are the kubectl arguments, with secrets redacted.
+	Args     []string `json:"args"`
+	Command  string   `json:"command,omitempty"`
+	Category string   `json:"category,omitempty"`
+	Risk     string   `json:"risk,omitempty"`
+	Rule     string   `json:"rule,omitempty"`
+	Mode     string   `json:"mode,omitempty"`
+
+	// Decision is the action the guard chose, e.g. "confirm".
+	Decision string   `json:"decision"`
+	Reasons  []string `json:"reasons,omitempty"`
+	// Outcome is what became of the command, and Confirmed whether a
+	// person or an approval let it run.
+	Outcome   Outcome `json:"outcome"`
+	Confirmed bool    `json:"confirmed"`
+	// Approval names what approved a command without a prompt.
+	Approval string `json:"approval,omitempty"`
+	// Reason is the --guard-reason given with the command.
+	Reason string `json:"reason,omitempty"`
+	// Error is why the command couldn't be checked.
+	Error string `json:"error,omitempty"`
+
+	// ExitCode, Signal and DurationMS describe how a supervised kubectl
+	// ended. ExitCode is nil when kubectl wasn't supervised.
+	ExitCode   *int   `json:"exit_code,omitempty"`
+	Signal     string `json:"signal,omitempty"`
+	DurationMS int64  `json:"duration_ms,omitempty"`
+
+	// PrevHash is the Hash of the record before this one, chaining the log
+	// so that an edited, removed or reordered record breaks the chain.
+	PrevHash string `json:"prev_hash,omitempty"`
+	// Hash is the SHA-256 of the record without Hash and HMAC, and HMAC
+	// the same record signed with the configured key.
+	Hash string `json:"hash,omitempty"`
+	HMAC string `json:"hmac,omitempty"`
+}
+
+// SetExit records how a supervised kubectl ended.
+func (r *Record) SetExit(exit guard.Exit) {
+	code := exit.Code
+	r.ExitCode = &code
+	r.Signal = exit.Signal
+	r.DurationMS = exit.Duration.Milliseconds()
+}
+
+// Records reports whether a decision belongs in the audit log: commands
+// that can change a protected context, or that couldn't be checked. Reads
+// are not recorded.
+func Records(d guard.Decision, checkErr error) bool {
+	if d.Classification.Category.IsSafe() {
+		return false
+	}
+	return d.Protected || checkErr != nil
+}
+
+// NewRecord describes a decision, stamped with the current time, user,
+// host and terminal.
+func NewRecord(d guard.Decision, checkErr error) Record {
+	r := Record{
+		ID:            newID(),
+		Time:          time.Now().UTC(),
+		User:          currentUser(),
+		TTY:           terminal(),
+		Context:       d.Target.Context,
+		Cluster:       d.Target.Cluster,
+		Server:        d.Target.Server,
+		Namespace:     d.Target.Namespace,
+		AllNamespaces: d.Target.AllNamespaces,
+		Args:          d.RedactedArgs(),
+		Command:       strings.Join(d.Classification.Path, " "),
+		Category:      string(d.Classification.Category),
+		Risk:          string(d.Risk.Level),
+		Mode:          string(d.Mode),
+		Decision:      d.Action.String(),
+		Reasons:       d.Reasons,
+	}
+	r.Host, _ = os.Hostname()
+	if d.Protected {
+		r.Rule = d.Rule.String()
+	}
+	if checkErr != nil {
+		r.Error = checkErr.Error()
+	}
+	return r
+}
+
+// Log appends records to a JSONL file, rotating it when it grows too big.
+type Log struct {
+	path     string
+	maxSize  int64
+	maxFiles int
+	// key signs records when an HMAC key is configured.
+	key []byte
+	// sinks receive a copy of each record.
+	sinks []Sink
+}
+
+// Open returns the log described by cfg, or nil if auditing is disabled.
+// Unset fields take their defaults.
+func Open(cfg config.AuditConfig) (*Log, error) {
+	if cfg.Disabled {
+		return nil, nil
+	}
+	path, err := resolvePath(cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+	l := &Log{path: path, maxSize: defaultMaxSizeMB << 20, maxFiles: defaultMaxFiles}
+	if cfg.MaxSizeMB > 0 {
+		l.maxSize = int64(cfg.MaxSizeMB) << 20
+	}
+	if cfg.MaxFiles > 0 {
+		l.maxFiles = cfg.MaxFiles
+	}
+	if cfg.HMACKeyFile != "" {
+		if l.key, err = readKey(cfg.HMACKeyFile); err != nil {
+			return nil, err
+		}
+	}
+	for _, sc := range cfg.Sinks {
+		sink, err := newSink(sc, filepath.Join(filepath.Dir(path), spoolDirName))
+		if err != nil {
+			return nil, err
+		}
+		l.sinks = append(l.sinks, sink)
+	}
+	return l, nil
+}
+
+// OpenConfigured opens the log configured in ~/.kubectl-guard.yaml. A
+// config that can't be loaded falls back to the default log, since that
+// is when a record matters most.
+func OpenConfigured() (*Log, error) {
+	var cfg config.AuditConfig
+	if c, err := config.Load(); err == nil {
+		cfg = c.Audit
+	}
+	return Open(cfg)
+}
+
+// Path returns the file records are appended to.
+func (l *Log) Path() string {
+	return l.path
+}
+
+// Append writes r to the log and forwards it to each sink. A record that
+// can't be written locally is still forwarded. It is a no-op on a nil Log.
+func (l *Log) Append(r Record) error {
+	if l == nil {
+		return nil
+	}
+	var errs []error
+	if err := l.write(&r); err != nil {
+		errs = append(errs, fmt.Errorf("could not write the audit log: %w", err))
+	}
+	for _, sink := range l.sinks {
+		if err := sink.Send(r); err != nil {
+			errs = append(errs, fmt.Errorf("could not forward the audit record to %s: %w", sink, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// write chains r to the last record and appends it as one line. Appends
+// from concurrent kubectl-guard processes are serialized with a lock file.
+func (l *Log) write(r *Record) error {
+	if err := os.MkdirAll(filepath.Dir(l.path), 0700); err != nil {
+		return err
+	}
+	unlock, err := l.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if r.PrevHash, err = l.lastHash(); err != nil {
+		return err
+	}
+	if err := r.seal(l.key); err != nil {
+		return err
+	}
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	if err := l.rotate(int64(len(line))); err != nil {
+		return fmt.Errorf("could not rotate %s: %w", l.path, err)
+	}
+
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return err
+	}
+	// Start on a new line if a previous write was cut off, so the partial
+	// record stays on its own line for Verify to report.
+	if info, err := f.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			line = append([]byte("\n"), line...)
+		}
+	}
+	if _, err := f.Write(line); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// rotate moves the log aside when appending incoming bytes would take it
+// past the size limit: path becomes path.1, path.1 becomes path.2, and so
+// on, dropping the oldest beyond maxFiles after anchoring the chain to its
+// last record.
+func (l *Log) rotate(incoming int64) error {
+	info, err := os.Stat(l.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Size() == 0 || info.Size()+incoming <= l.maxSize {
+		return nil
+	}
+
+	if err := l.saveAnchor(l.rotated(l.maxFiles)); err != nil {
+		return err
+	}
+	if err := os.Remove(l.rotated(l.maxFiles)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	for i := l.maxFiles - 1; i >= 1; i-- {
+		if err := os.Rename(l.rotated(i), l.rotated(i+1)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return os.Rename(l.path, l.rotated(1))
+}
+
+// rotated returns the name of the nth rotated log.
+func (l *Log) rotated(n int) string {
+	return fmt.Sprintf("%s.%d", l.path, n)
+}
+
+// ReadFile decodes every record in a JSONL file. Lines that can't be
+// decoded, such as a record cut off mid-write, are skipped and described
+// in the returned warnings, so one bad line doesn't hide the rest of the
+// log; audit verify reports them.
+func ReadFile(path string) ([]Record, []string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	var (
+		records  []Record
+		warnings []string
+	)
+	for i, line := range bytes.Split(data, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var r Record
+		if err := json.Unmarshal(line, &r); err != nil {
+			warnings = append(warnings, fmt.Sprintf("%s:%d: skipped a record that can't be decoded: %v", path, i+1, err))
+			continue
+		}
+		records = append(records, r)
+	}
+	return records, warnings, nil
+}
+
+// resolvePath expands a leading ~/ and applies the default path.
+func resolvePath(path string) (string, error) {
+	if path != "" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	if path == "" {
+		return filepath.Join(home, defaultDir, defaultFile), nil
+	}
+	return filepath.Join(home, path[2:]), nil
+}
+
+// newID returns a random identifier for a record.
+func newID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%016x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// currentUser returns the OS user name.
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// terminal returns the device of the terminal on stdin, stdout or stderr,
+// or "" when none of them is one. It relies on /proc or /dev/fd, so it is
+// best-effort.
+func terminal() string {
+	for fd := range 3 {
+		for _, dir := range []string{"/proc/self/fd", "/dev/fd"} {
+			name, err := os.Readlink(fmt.Sprintf("%s/%d", dir, fd))
+			if err != nil {
+				continue
+			}
+			if strings.HasPrefix(name, "/dev/pts/") || strings.HasPrefix(name, "/dev/tty") {
+				return name
+			}
+			break
+		}
+	}
+	return ""
+}
@@ -0,0 +1,196 @@
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cameronlockhart/kubectl-guard/config"
+	"github.com/cameronlockhart/kubectl-guard/guard"
+)
+
+func TestOpen(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	l, err := Open(config.AuditConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(home, ".kubectl-guard", "audit.jsonl"); l.Path() != want {
+		t.Errorf("default path = %s, want %s", l.Path(), want)
+	}
+	if l.maxSize != 10<<20 || l.maxFiles != 5 {
+		t.Errorf("defaults = %d bytes, %d files; want 10 MiB, 5 files", l.maxSize, l.maxFiles)
+	}
+
+	l, err = Open(config.AuditConfig{Path: "~/logs/guard.jsonl", MaxSizeMB: 1, MaxFiles: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(home, "logs", "guard.jsonl"); l.Path() != want {
+		t.Errorf("path = %s, want %s", l.Path(), want)
+	}
+	if l.maxSize != 1<<20 || l.maxFiles != 2 {
+		t.Errorf("limits = %d bytes, %d files; want 1 MiB, 2 files", l.maxSize, l.maxFiles)
+	}
+
+	if l, err := Open(config.AuditConfig{Disabled: true}); l != nil || err != nil {
+		t.Errorf("Open(disabled) = (%v, %v), want (nil, nil)", l, err)
+	}
+	// A nil log accepts records and drops them.
+	var disabled *Log
+	if err := disabled.Append(Record{}); err != nil {
+		t.Errorf("nil Log Append() error = %v", err)
+	}
+}
+
+func TestAppend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit", "audit.jsonl")
+	l := &Log{path: path, maxSize: 1 << 20, maxFiles: 2}
+
+	for _, outcome := range []Outcome{OutcomeConfirmed, OutcomeAborted} {
+		if err := l.Append(Record{ID: string(outcome), Outcome: outcome}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	records, _, err := ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Outcome != OutcomeConfirmed || records[1].Outcome != OutcomeAborted {
+		t.Errorf("records = %+v, want confirmed then aborted", records)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("log mode = %v, want 0600", info.Mode().Perm())
+	}
+}
+
+func TestRotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	// Each record is well over 100 bytes, so every append rotates.
+	l := &Log{path: path, maxSize: 100, maxFiles: 2}
+
+	for _, id := range []string{"first", "second", "third", "fourth"} {
+		if err := l.Append(Record{ID: id, Args: []string{strings.Repeat("x", 100)}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for file, want := range map[string]string{path: "fourth", path + ".1": "third", path + ".2": "second"} {
+		records, _, err := ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) != 1 || records[0].ID != want {
+			t.Errorf("%s holds %+v, want only %s", filepath.Base(file), records, want)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("%s.3 exists, want at most 2 rotated logs", path)
+	}
+}
+
+func TestReadFileSkipsBadLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	data := `{"id":"a"}` + "\n{not json\n" + `{"id":"b"}` + "\n" + `{"id":"c","ti`
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	records, warnings, err := ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].ID != "a" || records[1].ID != "b" {
+		t.Errorf("ReadFile() = %+v, want records a and b", records)
+	}
+	if len(warnings) != 2 || !strings.Contains(warnings[0], ":2:") || !strings.Contains(warnings[1], ":4:") {
+		t.Errorf("ReadFile() warnings = %q, want lines 2 and 4", warnings)
+	}
+}
+
+func TestRecords(t *testing.T) {
+	read := guard.Decision{Protected: true, Classification: guard.Classification{Category: guard.CategoryRead}}
+	write := guard.Decision{Protected: true, Classification: guard.Classification{Category: guard.CategoryWriteCluster}}
+	unprotected := guard.Decision{Classification: guard.Classification{Category: guard.CategoryWriteCluster}}
+
+	tests := []struct {
+		name     string
+		d        guard.Decision
+		checkErr error
+		want     bool
+	}{
+		{"read on protected context", read, nil, false},
+		{"write on protected context", write, nil, true},
+		{"write on unprotected context", unprotected, nil, false},
+		{"write that couldn't be checked", unprotected, errors.New("bad config"), true},
+	}
+	for _, tt := range tests {
+		if got := Records(tt.d, tt.checkErr); got != tt.want {
+			t.Errorf("Records(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNewRecord(t *testing.T) {
+	d := guard.Decision{
+		Action:         guard.RequireConfirmation,
+		Args:           []string{"delete", "pod", "web", "--token=abc"},
+		Classification: guard.Classification{Path: []string{"delete"}, Category: guard.CategoryWriteCluster},
+		Target:         guard.Target{Context: "prod", Cluster: "prod-cluster", Namespace: "payments"},
+		Protected:      true,
+		Rule:           config.Rule{Context: "prod"},
+		Mode:           config.ModeConfirm,
+		Risk:           guard.Risk{Level: config.RiskLow},
+		Reasons:        []string{"context prod matches rule context=prod"},
+	}
+
+	r := NewRecord(d, nil)
+	if r.ID == "" || r.Time.IsZero() || r.User == "" {
+		t.Errorf("record is missing its id, time or user: %+v", r)
+	}
+	if r.Context != "prod" || r.Cluster != "prod-cluster" || r.Namespace != "payments" {
+		t.Errorf("target = %s/%s/%s, want prod/prod-cluster/payments", r.Context, r.Cluster, r.Namespace)
+	}
+	if r.Command != "delete" || r.Category != "write-cluster" || r.Risk != "low" || r.Rule != "context=prod" {
+		t.Errorf("classification = %s/%s/%s/%s", r.Command, r.Category, r.Risk, r.Rule)
+	}
+	if r.Decision != "confirm" || len(r.Reasons) != 1 {
+		t.Errorf("decision = %s with reasons %q", r.Decision, r.Reasons)
+	}
+	if want := []string{"delete", "pod", "web", "--token=REDACTED"}; !slices.Equal(r.Args, want) {
+		t.Errorf("args = %q, want %q", r.Args, want)
+	}
+	if r2 := NewRecord(d, nil); r2.ID == r.ID {
+		t.Errorf("two records share the id %s", r.ID)
+	}
+}
+
+func TestSetExit(t *testing.T) {
+	var r Record
+	r.SetExit(guard.Exit{Code: 0, Duration: 1500 * time.Millisecond})
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{`"exit_code":0`, `"duration_ms":1500`} {
+		if !strings.Contains(string(data), key) {
+			t.Errorf("record %s missing %s", data, key)
+		}
+	}
+	if data, _ := json.Marshal(Record{}); strings.Contains(string(data), "exit_code") {
+		t.Errorf("unsupervised record %s has an exit_code", data)
+	}
+}
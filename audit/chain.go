@@ -0,0 +1,356 @@
+package audit
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// tailChunk is how much of the log lastHash reads at a time, from the end.
+const tailChunk = 64 << 10
+
+// Kinds of problem Verify reports.
+const (
+	ProblemTruncated  = "truncated tail"
+	ProblemMalformed  = "malformed entry"
+	ProblemUnchained  = "missing hash"
+	ProblemModified   = "modified entry"
+	ProblemBrokenLink = "broken link"
+	ProblemHMAC       = "bad HMAC"
+)
+
+// ChainError is the first place the audit log fails verification.
+type ChainError struct {
+	File string
+	Line int
+	// ID is the record's id, when the line could be decoded.
+	ID     string
+	Kind   string
+	Detail string
+}
+
+func (e *ChainError) Error() string {
+	msg := fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Kind)
+	if e.ID != "" {
+		msg += " in record " + e.ID
+	}
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
+	return msg
+}
+
+// Verification summarizes a verified audit log.
+type Verification struct {
+	Files   int
+	Records int
+	// Unchained counts records without a hash at the start of the log.
+	// They may predate hash chaining or have been stripped of their hashes
+	// and altered; nothing about them is checked.
+	Unchained int
+	// Signed counts records whose HMAC was checked, and Unsigned records
+	// with an HMAC that couldn't be checked because no key is configured.
+	Signed   int
+	Unsigned int
+	// Head is the hash of the last record. Keeping a copy elsewhere lets a
+	// later verify notice records removed from the end.
+	Head string
+}
+
+// digest returns the bytes a record's hash and HMAC cover: the record as
+// JSON without them.
+func (r Record) digest() ([]byte, error) {
+	r.Hash, r.HMAC = "", ""
+	return json.Marshal(r)
+}
+
+// seal sets the record's hash, and its HMAC when there is a key.
+func (r *Record) seal(key []byte) error {
+	data, err := r.digest()
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	r.Hash = hex.EncodeToString(sum[:])
+	r.HMAC = ""
+	if len(key) > 0 {
+		r.HMAC = sign(key, data)
+	}
+	return nil
+}
+
+func sign(key, data []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// anchor is the hash of the last record rotated out of the log. The oldest
+// retained record must link to it, so records removed from the start of
+// the log are noticed too.
+type anchor struct {
+	Hash string `json:"hash"`
+	// HMAC signs Hash when the log has a key.
+	HMAC string `json:"hmac,omitempty"`
+}
+
+// anchorPath returns the file the anchor is kept in, beside the log.
+func (l *Log) anchorPath() string {
+	return l.path + ".anchor"
+}
+
+// saveAnchor records the hash of the last record in a rotated file that is
+// about to be dropped. A file whose last line can't be decoded leaves the
+// old anchor in place, for Verify to report the gap.
+func (l *Log) saveAnchor(dropped string) error {
+	line, err := lastLine(dropped)
+	if errors.Is(err, fs.ErrNotExist) || line == nil {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// Records from before chaining leave nothing to link to.
+	var r Record
+	if json.Unmarshal(line, &r) != nil || r.Hash == "" {
+		return nil
+	}
+	a := anchor{Hash: r.Hash}
+	if len(l.key) > 0 {
+		a.HMAC = sign(l.key, []byte(a.Hash))
+	}
+	data, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	tmp := l.anchorPath() + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, l.anchorPath())
+}
+
+// readAnchor returns the hash the oldest retained record must link to, and
+// whether there is an anchor at all: there isn't until chained records have
+// been rotated out.
+func (l *Log) readAnchor() (string, bool, error) {
+	path := l.anchorPath()
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	var a anchor
+	if err := json.Unmarshal(data, &a); err != nil {
+		return "", false, &ChainError{File: path, Line: 1, Kind: ProblemMalformed, Detail: err.Error()}
+	}
+	if len(l.key) > 0 && !hmac.Equal([]byte(a.HMAC), []byte(sign(l.key, []byte(a.Hash)))) {
+		return "", false, &ChainError{File: path, Line: 1, Kind: ProblemHMAC, Detail: "the anchor's HMAC doesn't match the configured key"}
+	}
+	return a.Hash, true, nil
+}
+
+// Verify walks the log from the oldest rotated file to the current one,
+// checking that every record hashes to its Hash, links to the one before
+// it and, with a key, carries a valid HMAC. It stops at the first problem
+// and returns it as a *ChainError.
+//
+// Records without a hash are only accepted at the start of the oldest file,
+// before the first chained record, and only when there is neither a key
+// nor an anchor: either proves the log was chained, so a record without a
+// hash can only have been stripped of it. Accepted records are counted as
+// Unchained, since nothing about them is checked. The oldest chained record
+// must link to the anchor left by rotation, or to nothing if the log has
+// never rotated records away.
+func (l *Log) Verify() (Verification, error) {
+	var (
+		v       Verification
+		chained bool
+	)
+	prev, anchored, err := l.readAnchor()
+	if err != nil {
+		return v, err
+	}
+	for i := l.maxFiles; i >= 0; i-- {
+		path := l.path
+		if i > 0 {
+			path = l.rotated(i)
+		}
+		data, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return v, err
+		}
+		v.Files++
+
+		lines := bytes.Split(data, []byte("\n"))
+		for n, line := range lines {
+			if len(bytes.TrimSpace(line)) == 0 {
+				continue
+			}
+			fail := func(id, kind, detail string) (Verification, error) {
+				return v, &ChainError{File: path, Line: n + 1, ID: id, Kind: kind, Detail: detail}
+			}
+			// Split leaves whatever follows the last newline as the final
+			// element, so a non-empty one is a record cut off mid-write.
+			if n == len(lines)-1 {
+				return fail("", ProblemTruncated, "the last line is incomplete")
+			}
+			var r Record
+			if err := json.Unmarshal(line, &r); err != nil {
+				return fail("", ProblemMalformed, err.Error())
+			}
+			if r.Hash == "" {
+				switch {
+				case chained:
+					return fail(r.ID, ProblemUnchained, "record has no hash but follows chained records")
+				case len(l.key) > 0:
+					return fail(r.ID, ProblemUnchained, "record has no hash, but a key is configured")
+				case anchored:
+					return fail(r.ID, ProblemUnchained, "record has no hash, but chained records were rotated out before it")
+				case v.Files > 1:
+					return fail(r.ID, ProblemUnchained, "record has no hash, but is not in the oldest file")
+				}
+				v.Records++
+				v.Unchained++
+				continue
+			}
+
+			data, err := r.digest()
+			if err != nil {
+				return v, err
+			}
+			sum := sha256.Sum256(data)
+			if hash := hex.EncodeToString(sum[:]); hash != r.Hash {
+				return fail(r.ID, ProblemModified, "contents don't match its hash")
+			}
+			switch {
+			case r.PrevHash == prev:
+			case !chained:
+				return fail(r.ID, ProblemBrokenLink, "prev_hash doesn't match the last record rotated out of the log; records were removed from the start")
+			default:
+				return fail(r.ID, ProblemBrokenLink, "prev_hash doesn't match the record before it; records were removed, inserted or reordered")
+			}
+
+			switch {
+			case len(l.key) == 0:
+				if r.HMAC != "" {
+					v.Unsigned++
+				}
+			case r.HMAC == "":
+				return fail(r.ID, ProblemHMAC, "record is not signed, but a key is configured")
+			case !hmac.Equal([]byte(r.HMAC), []byte(sign(l.key, data))):
+				return fail(r.ID, ProblemHMAC, "HMAC doesn't match the configured key")
+			default:
+				v.Signed++
+			}
+
+			chained = true
+			prev = r.Hash
+			v.Records++
+		}
+	}
+	v.Head = prev
+	return v, nil
+}
+
+// lastHash returns the hash of the last record, looking in the most recent
+// rotated file when the current one is empty or missing. It is "" for a new
+// log or one written before chaining.
+func (l *Log) lastHash() (string, error) {
+	for _, path := range []string{l.path, l.rotated(1)} {
+		line, err := lastLine(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if line == nil {
+			continue
+		}
+		var r Record
+		if err := json.Unmarshal(line, &r); err != nil {
+			return "", fmt.Errorf("could not chain to the last record in %s: %w", path, err)
+		}
+		return r.Hash, nil
+	}
+	return "", nil
+}
+
+// lastLine returns the last complete line of a file without reading all of
+// it, or nil if the file has none.
+func lastLine(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	// Read backwards a chunk at a time until the buffer holds the newline
+	// that ends the line before the last one, or the start of the file.
+	var buf []byte
+	end := info.Size()
+	for offset := end; offset > 0; {
+		n := min(offset, tailChunk)
+		offset -= n
+		chunk := make([]byte, n)
+		if _, err := f.ReadAt(chunk, offset); err != nil && err != io.EOF {
+			return nil, err
+		}
+		buf = append(chunk, buf...)
+
+		// Ignore anything after the last newline: it is a record cut off
+		// mid-write, which Verify reports.
+		last := bytes.LastIndexByte(buf, '\n')
+		if last < 0 {
+			continue
+		}
+		content := bytes.TrimRight(buf[:last], "\n")
+		if start := bytes.LastIndexByte(content, '\n'); start >= 0 {
+			return content[start+1:], nil
+		}
+		if offset == 0 && len(content) > 0 {
+			return content, nil
+		}
+	}
+	return nil, nil
+}
+
+// lock takes an exclusive lock on a file beside the log, since the log
+// itself is renamed by rotation. The returned function releases it.
+func (l *Log) lock() (func(), error) {
+	return lockFile(l.path + ".lock")
+}
+
+// readKey reads the HMAC key from a file, ignoring surrounding whitespace.
+func readKey(path string) ([]byte, error) {
+	path, err := resolvePath(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the audit HMAC key: %w", err)
+	}
+	key := bytes.TrimSpace(data)
+	if len(key) == 0 {
+		return nil, fmt.Errorf("audit HMAC key file %s is empty", path)
+	}
+	return key, nil
+}
@@ -0,0 +1,419 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cameronlockhart/kubectl-guard/config"
+)
+
+// chainedLog appends n records to a new log and returns it.
+func chainedLog(t *testing.T, key []byte, n int) *Log {
+	t.Helper()
+	l := &Log{path: filepath.Join(t.TempDir(), "audit.jsonl"), maxSize: 1 << 20, maxFiles: 2, key: key}
+	for i := range n {
+		if err := l.Append(Record{ID: fmt.Sprintf("r%d", i), Args: []string{"delete", "pod", fmt.Sprint(i)}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return l
+}
+
+// editLog applies edit to the lines of the log's current file.
+func editLog(t *testing.T, l *Log, edit func(lines []string) []string) {
+	t.Helper()
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := edit(strings.Split(strings.TrimSuffix(string(data), "\n"), "\n"))
+	if err := os.WriteFile(l.path, []byte(strings.Join(lines, "\n")+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAppendChains(t *testing.T) {
+	l := chainedLog(t, nil, 3)
+
+	records, _, err := ReadFile(l.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if records[0].PrevHash != "" {
+		t.Errorf("first record links to %s, want nothing", records[0].PrevHash)
+	}
+	for i := 1; i < len(records); i++ {
+		if records[i].Hash == "" || records[i].PrevHash != records[i-1].Hash {
+			t.Errorf("record %d prev_hash = %s, want %s", i, records[i].PrevHash, records[i-1].Hash)
+		}
+	}
+	if records[0].HMAC != "" {
+		t.Errorf("record signed without a key: %s", records[0].HMAC)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(lines []string) []string
+		kind string
+		line int
+	}{
+		{"intact", func(lines []string) []string { return lines }, "", 0},
+		{
+			"modified entry",
+			func(lines []string) []string {
+				lines[1] = strings.Replace(lines[1], `"pod","1"`, `"pod","9"`, 1)
+				return lines
+			},
+			ProblemModified, 2,
+		},
+		{
+			"removed entry",
+			func(lines []string) []string { return append(lines[:1], lines[2:]...) },
+			ProblemBrokenLink, 2,
+		},
+		{
+			"removed first entry",
+			func(lines []string) []string { return lines[1:] },
+			ProblemBrokenLink, 1,
+		},
+		{
+			"reordered entries",
+			func(lines []string) []string {
+				lines[1], lines[2] = lines[2], lines[1]
+				return lines
+			},
+			ProblemBrokenLink, 2,
+		},
+		{
+			"unchained entry after chained ones",
+			func(lines []string) []string { return append(lines, `{"id":"forged","args":[]}`) },
+			ProblemUnchained, 4,
+		},
+		{
+			"malformed entry",
+			func(lines []string) []string {
+				lines[0] = "{not json"
+				return lines
+			},
+			ProblemMalformed, 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := chainedLog(t, nil, 3)
+			editLog(t, l, tt.edit)
+
+			v, err := l.Verify()
+			if tt.kind == "" {
+				if err != nil || v.Records != 3 || v.Head == "" {
+					t.Errorf("Verify() = (%+v, %v), want 3 records and a head", v, err)
+				}
+				return
+			}
+			var chainErr *ChainError
+			if !errors.As(err, &chainErr) {
+				t.Fatalf("Verify() error = %v, want a ChainError", err)
+			}
+			if chainErr.Kind != tt.kind || chainErr.Line != tt.line {
+				t.Errorf("Verify() error = %v, want %s on line %d", err, tt.kind, tt.line)
+			}
+		})
+	}
+}
+
+func TestVerifyTruncatedTail(t *testing.T) {
+	l := chainedLog(t, nil, 2)
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"id":"r2","ti`)
+	f.Close()
+
+	var chainErr *ChainError
+	if _, err := l.Verify(); !errors.As(err, &chainErr) || chainErr.Kind != ProblemTruncated || chainErr.Line != 3 {
+		t.Errorf("Verify() error = %v, want a truncated tail on line 3", err)
+	}
+
+	// The next record starts on a line of its own and still chains to the
+	// last complete record, leaving the partial one to be reported.
+	if err := l.Append(Record{ID: "r3"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Verify(); !errors.As(err, &chainErr) || chainErr.Kind != ProblemMalformed || chainErr.Line != 3 {
+		t.Errorf("Verify() error = %v, want a malformed entry on line 3", err)
+	}
+	if last, err := lastLine(l.path); err != nil || !strings.HasPrefix(string(last), `{"id":"r3"`) {
+		t.Errorf("last line = (%s, %v), want record r3", last, err)
+	}
+}
+
+func TestVerifyHMAC(t *testing.T) {
+	key := []byte("s3cret")
+	l := chainedLog(t, key, 3)
+
+	v, err := l.Verify()
+	if err != nil || v.Signed != 3 {
+		t.Errorf("Verify() = (%+v, %v), want 3 signed records", v, err)
+	}
+
+	// Without the key, the chain still verifies but the HMACs can't be.
+	unkeyed := *l
+	unkeyed.key = nil
+	if v, err := unkeyed.Verify(); err != nil || v.Unsigned != 3 || v.Signed != 0 {
+		t.Errorf("Verify() without a key = (%+v, %v), want 3 unchecked HMACs", v, err)
+	}
+
+	// Someone without the key can recompute the hash chain, but not the
+	// HMACs.
+	forged := *l
+	forged.key = []byte("guess")
+	var chainErr *ChainError
+	if _, err := forged.Verify(); !errors.As(err, &chainErr) || chainErr.Kind != ProblemHMAC || chainErr.Line != 1 {
+		t.Errorf("Verify() with the wrong key error = %v, want a bad HMAC on line 1", err)
+	}
+
+	unsigned := unkeyed
+	if err := unsigned.Append(Record{ID: "unsigned"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Verify(); !errors.As(err, &chainErr) || chainErr.Kind != ProblemHMAC || chainErr.Line != 4 {
+		t.Errorf("Verify() error = %v, want an unsigned record on line 4", err)
+	}
+
+	// With a key, every chained record must be signed, even when none
+	// before it was: otherwise a log rewritten without HMACs would pass.
+	rewritten := chainedLog(t, nil, 2)
+	rewritten.key = key
+	if _, err := rewritten.Verify(); !errors.As(err, &chainErr) || chainErr.Kind != ProblemHMAC || chainErr.Line != 1 {
+		t.Errorf("Verify() of an unsigned log error = %v, want an unsigned record on line 1", err)
+	}
+}
+
+func TestVerifyUnchainedPrefix(t *testing.T) {
+	l := &Log{path: filepath.Join(t.TempDir(), "audit.jsonl"), maxSize: 1 << 20, maxFiles: 2}
+	old := `{"id":"old1","args":[]}` + "\n" + `{"id":"old2","args":[]}` + "\n"
+	if err := os.WriteFile(l.path, []byte(old), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Append(Record{ID: "new"}); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := l.Verify()
+	if err != nil || v.Records != 3 || v.Unchained != 2 {
+		t.Errorf("Verify() = (%+v, %v), want 3 records, 2 unchained", v, err)
+	}
+}
+
+// stripChain removes the hash, link and HMAC from every record in a log
+// file and changes its decision to "allow", as someone rewriting history
+// without the key would.
+func stripChain(t *testing.T, path string) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []byte
+	for line := range strings.Lines(string(data)) {
+		var r Record
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatal(err)
+		}
+		r.Hash, r.PrevHash, r.HMAC = "", "", ""
+		r.Decision = "allow"
+		stripped, err := json.Marshal(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(append(out, stripped...), '\n')
+	}
+	if err := os.WriteFile(path, out, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyStrippedChain(t *testing.T) {
+	var chainErr *ChainError
+
+	// A key proves the log was meant to be chained.
+	keyed := chainedLog(t, []byte("s3cret"), 3)
+	stripChain(t, keyed.path)
+	if _, err := keyed.Verify(); !errors.As(err, &chainErr) || chainErr.Kind != ProblemUnchained || chainErr.Line != 1 {
+		t.Errorf("Verify() of a stripped keyed log error = %v, want a missing hash on line 1", err)
+	}
+
+	// So does an anchor left by rotating chained records away.
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	anchored := &Log{path: path, maxSize: 100, maxFiles: 1}
+	for _, id := range []string{"first", "second", "third"} {
+		if err := anchored.Append(Record{ID: id, Decision: "block", Args: []string{strings.Repeat("x", 100)}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stripChain(t, path+".1")
+	stripChain(t, path)
+	if _, err := anchored.Verify(); !errors.As(err, &chainErr) || chainErr.Kind != ProblemUnchained || chainErr.File != path+".1" {
+		t.Errorf("Verify() of a stripped rotated log error = %v, want a missing hash in %s.1", err, path)
+	}
+
+	// Without either, records without a hash are only accepted at the start
+	// of the oldest file.
+	path = filepath.Join(t.TempDir(), "audit.jsonl")
+	rotated := &Log{path: path, maxSize: 100, maxFiles: 2}
+	for _, id := range []string{"first", "second"} {
+		if err := rotated.Append(Record{ID: id, Decision: "block", Args: []string{strings.Repeat("x", 100)}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stripChain(t, path+".1")
+	stripChain(t, path)
+	if _, err := rotated.Verify(); !errors.As(err, &chainErr) || chainErr.Kind != ProblemUnchained || chainErr.File != path {
+		t.Errorf("Verify() of a stripped newer file error = %v, want a missing hash in %s", err, path)
+	}
+}
+
+func TestVerifyAcrossRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	// Every append rotates, so the first record falls off the end and the
+	// oldest kept one links to a record that is gone.
+	l := &Log{path: path, maxSize: 100, maxFiles: 2}
+	for _, id := range []string{"first", "second", "third", "fourth"} {
+		if err := l.Append(Record{ID: id, Args: []string{strings.Repeat("x", 100)}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	v, err := l.Verify()
+	if err != nil || v.Files != 3 || v.Records != 3 {
+		t.Errorf("Verify() = (%+v, %v), want 3 records in 3 files", v, err)
+	}
+
+	// Removing the middle file breaks the link between the other two.
+	if err := os.Rename(path+".1", path+".1.bak"); err != nil {
+		t.Fatal(err)
+	}
+	var chainErr *ChainError
+	if _, err := l.Verify(); !errors.As(err, &chainErr) || chainErr.Kind != ProblemBrokenLink || chainErr.File != path {
+		t.Errorf("Verify() error = %v, want a broken link in %s", err, path)
+	}
+	if err := os.Rename(path+".1.bak", path+".1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Removing the oldest file no longer matches the anchor rotation left.
+	if err := os.Remove(path + ".2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Verify(); !errors.As(err, &chainErr) || chainErr.Kind != ProblemBrokenLink || chainErr.File != path+".1" || chainErr.Line != 1 {
+		t.Errorf("Verify() error = %v, want a broken link at the start of %s.1", err, path)
+	}
+}
+
+func TestVerifyAnchorHMAC(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	l := &Log{path: path, maxSize: 100, maxFiles: 1, key: []byte("s3cret")}
+	for _, id := range []string{"first", "second", "third"} {
+		if err := l.Append(Record{ID: id, Args: []string{strings.Repeat("x", 100)}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if v, err := l.Verify(); err != nil || v.Records != 2 {
+		t.Errorf("Verify() = (%+v, %v), want 2 records", v, err)
+	}
+
+	// Cutting the oldest file and forging the anchor to match needs the key.
+	records, _, err := ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(path + ".1"); err != nil {
+		t.Fatal(err)
+	}
+	forged := fmt.Sprintf(`{"hash":%q}`, records[0].PrevHash)
+	if err := os.WriteFile(path+".anchor", []byte(forged+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	var chainErr *ChainError
+	if _, err := l.Verify(); !errors.As(err, &chainErr) || chainErr.Kind != ProblemHMAC || chainErr.File != path+".anchor" {
+		t.Errorf("Verify() error = %v, want a bad HMAC on the anchor", err)
+	}
+}
+
+func TestAppendConcurrently(t *testing.T) {
+	l := chainedLog(t, nil, 0)
+
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := l.Append(Record{ID: fmt.Sprint(i)}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if v, err := l.Verify(); err != nil || v.Records != 20 {
+		t.Errorf("Verify() = (%+v, %v), want 20 chained records", v, err)
+	}
+}
+
+func TestLastLine(t *testing.T) {
+	long := strings.Repeat("x", tailChunk+10)
+	tests := []struct {
+		name, data, want string
+	}{
+		{"empty", "", ""},
+		{"one line", "a\n", "a"},
+		{"several lines", "a\nb\nc\n", "c"},
+		{"trailing blank lines", "a\nb\n\n\n", "b"},
+		{"partial tail", "a\nb\npartial", "b"},
+		{"only a partial line", "partial", ""},
+		{"line longer than a chunk", "a\n" + long + "\n", long},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "log")
+		if err := os.WriteFile(path, []byte(tt.data), 0600); err != nil {
+			t.Fatal(err)
+		}
+		got, err := lastLine(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, []byte(tt.want)) {
+			t.Errorf("%s: lastLine() = %.20q, want %.20q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestOpenHMACKey(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if err := os.WriteFile(filepath.Join(home, "key"), []byte("s3cret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := Open(config.AuditConfig{HMACKeyFile: "~/key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(l.key) != "s3cret" {
+		t.Errorf("key = %q, want s3cret", l.key)
+	}
+
+	if _, err := Open(config.AuditConfig{HMACKeyFile: "~/missing"}); err == nil {
+		t.Error("Open() with a missing key file succeeded, want an error")
+	}
+}
@@ -0,0 +1,138 @@
+package audit
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Filter selects audit records. Empty fields match everything.
+type Filter struct {
+	// Context is a glob matched against the record's context.
+	Context string
+	User    string
+	// Verb matches the command's first word, e.g. "delete", or its whole
+	// path, e.g. "rollout restart".
+	Verb string
+	// Decision matches either the decision ("confirm", "block") or the
+	// outcome ("aborted", "confirmed").
+	Decision string
+	// Since and Until bound the record's time.
+	Since time.Time
+	Until time.Time
+}
+
+// Match reports whether the record passes the filter.
+func (f Filter) Match(r Record) bool {
+	if f.Context != "" {
+		if matched, _ := filepath.Match(f.Context, r.Context); !matched {
+			return false
+		}
+	}
+	if f.User != "" && f.User != r.User {
+		return false
+	}
+	if f.Verb != "" && f.Verb != r.Verb() && f.Verb != r.Command {
+		return false
+	}
+	if f.Decision != "" && f.Decision != r.Decision && f.Decision != string(r.Outcome) {
+		return false
+	}
+	if !f.Since.IsZero() && r.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && r.Time.After(f.Until) {
+		return false
+	}
+	return true
+}
+
+// Select returns the records that pass the filter, in order.
+func (f Filter) Select(records []Record) []Record {
+	var out []Record
+	for _, r := range records {
+		if f.Match(r) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// Verb returns the first word of the command, e.g. "rollout".
+func (r Record) Verb() string {
+	verb, _, _ := strings.Cut(r.Command, " ")
+	return verb
+}
+
+// Records reads every record in the log, rotated files first, oldest to
+// newest. Missing files are skipped, and so are lines that can't be
+// decoded, which are described in the returned warnings.
+func (l *Log) Records() ([]Record, []string, error) {
+	var (
+		records  []Record
+		warnings []string
+	)
+	for i := l.maxFiles; i >= 0; i-- {
+		path := l.path
+		if i > 0 {
+			path = l.rotated(i)
+		}
+		rs, ws, err := ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return records, warnings, err
+		}
+		records = append(records, rs...)
+		warnings = append(warnings, ws...)
+	}
+	return records, warnings, nil
+}
+
+// Find returns the record whose ID is id, or starts with it if only one
+// does.
+func Find(records []Record, id string) (Record, error) {
+	var found []Record
+	for _, r := range records {
+		if r.ID == id {
+			return r, nil
+		}
+		if id != "" && strings.HasPrefix(r.ID, id) {
+			found = append(found, r)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return Record{}, fmt.Errorf("no audit record %s", id)
+	case 1:
+		return found[0], nil
+	default:
+		return Record{}, fmt.Errorf("%d audit records start with %s", len(found), id)
+	}
+}
+
+// ParseTime reads a --since or --until value relative to now: a duration
+// ago such as "90m", "24h" or "7d", a date ("2006-01-02") or an RFC 3339
+// time.
+func ParseTime(s string, now time.Time) (time.Time, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, now.Location()); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q (want e.g. 24h, 7d, 2006-01-02 or an RFC 3339 time)", s)
+}
@@ -0,0 +1,142 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFilterMatch(t *testing.T) {
+	at := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	r := Record{
+		Time:     at,
+		User:     "alice",
+		Context:  "prod-eu",
+		Command:  "rollout restart",
+		Decision: "confirm",
+		Outcome:  OutcomeAborted,
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   bool
+	}{
+		{"empty filter", Filter{}, true},
+		{"context glob", Filter{Context: "prod-*"}, true},
+		{"other context", Filter{Context: "staging"}, false},
+		{"user", Filter{User: "alice"}, true},
+		{"other user", Filter{User: "bob"}, false},
+		{"verb", Filter{Verb: "rollout"}, true},
+		{"command path", Filter{Verb: "rollout restart"}, true},
+		{"other verb", Filter{Verb: "delete"}, false},
+		{"decision", Filter{Decision: "confirm"}, true},
+		{"outcome", Filter{Decision: "aborted"}, true},
+		{"other decision", Filter{Decision: "block"}, false},
+		{"since before", Filter{Since: at.Add(-time.Hour)}, true},
+		{"since after", Filter{Since: at.Add(time.Hour)}, false},
+		{"until after", Filter{Until: at.Add(time.Hour)}, true},
+		{"until before", Filter{Until: at.Add(-time.Hour)}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.filter.Match(r); got != tt.want {
+			t.Errorf("%s: Match() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	records := []Record{{ID: "a", User: "alice"}, {ID: "b", User: "bob"}, {ID: "c", User: "alice"}}
+	got := Filter{User: "alice"}.Select(records)
+	if len(got) != 2 || got[0].ID != "a" || got[1].ID != "c" {
+		t.Errorf("Select() = %+v, want a and c", got)
+	}
+}
+
+func TestFind(t *testing.T) {
+	records := []Record{{ID: "abc123"}, {ID: "abd456"}, {ID: "ab"}}
+
+	if r, err := Find(records, "abc"); err != nil || r.ID != "abc123" {
+		t.Errorf("Find(abc) = (%s, %v), want abc123", r.ID, err)
+	}
+	// An exact match wins over the records it is a prefix of.
+	if r, err := Find(records, "ab"); err != nil || r.ID != "ab" {
+		t.Errorf("Find(ab) = (%s, %v), want ab", r.ID, err)
+	}
+	if _, err := Find(records[:2], "ab"); err == nil || !strings.Contains(err.Error(), "2 audit records") {
+		t.Errorf("Find(ambiguous) error = %v, want one counting the matches", err)
+	}
+	if _, err := Find(records, "zz"); err == nil {
+		t.Error("Find(zz) succeeded, want an error")
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"7d", now.AddDate(0, 0, -7)},
+		{"24h", now.Add(-24 * time.Hour)},
+		{"90m", now.Add(-90 * time.Minute)},
+		{"2026-10-01", time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)},
+		{"2026-10-01T08:30:00Z", time.Date(2026, 10, 1, 8, 30, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := ParseTime(tt.in, now)
+		if err != nil {
+			t.Errorf("ParseTime(%q) error = %v", tt.in, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("ParseTime(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{"", "yesterday", "-3d", "2026-13-01"} {
+		if _, err := ParseTime(in, now); err == nil {
+			t.Errorf("ParseTime(%q) succeeded, want an error", in)
+		}
+	}
+}
+
+func TestLogRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	l := &Log{path: path, maxSize: 100, maxFiles: 2}
+
+	if records, _, err := l.Records(); err != nil || len(records) != 0 {
+		t.Errorf("Records() of an empty log = (%v, %v), want none", records, err)
+	}
+
+	// Every append rotates, so "first" falls off the end.
+	for _, id := range []string{"first", "second", "third", "fourth"} {
+		if err := l.Append(Record{ID: id, Args: []string{strings.Repeat("x", 100)}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	records, _, err := l.Records()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, r := range records {
+		ids = append(ids, r.ID)
+	}
+	if got := strings.Join(ids, ","); got != "second,third,fourth" {
+		t.Errorf("Records() = %s, want second,third,fourth", got)
+	}
+
+	if err := os.WriteFile(path+".1", []byte("{not json\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	// A bad line is skipped with a warning, and the rest still read.
+	records, warnings, err := l.Records()
+	if err != nil || len(records) != 2 || records[0].ID != "second" || records[1].ID != "fourth" {
+		t.Errorf("Records() = (%+v, %v), want second and fourth", records, err)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], path+".1:1:") {
+		t.Errorf("Records() warnings = %q, want one naming %s.1:1", warnings, path)
+	}
+}
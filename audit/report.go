@@ -0,0 +1,273 @@
+package audit
+
+import (
+	"cmp"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// topVerbs is how many of the most frequent verbs a summary lists.
+const topVerbs = 3
+
+// Format is an output format for records and reports.
+type Format string
+
+// Output formats for the audit commands.
+const (
+	FormatTable    Format = "table"
+	FormatJSON     Format = "json"
+	FormatCSV      Format = "csv"
+	FormatMarkdown Format = "markdown"
+)
+
+// Formats lists the output formats.
+var Formats = []Format{FormatTable, FormatJSON, FormatCSV, FormatMarkdown}
+
+// ParseFormat validates an output format name; "md" is short for markdown.
+func ParseFormat(s string) (Format, error) {
+	if s == "md" {
+		return FormatMarkdown, nil
+	}
+	if f := Format(s); slices.Contains(Formats, f) {
+		return f, nil
+	}
+	return "", fmt.Errorf("invalid format %q (want table, json, csv or markdown)", s)
+}
+
+// Summary counts the outcomes recorded for one context.
+type Summary struct {
+	Context    string `json:"context"`
+	Total      int    `json:"total"`
+	Confirmed  int    `json:"confirmed"`
+	Approved   int    `json:"approved"`
+	Warned     int    `json:"warned"`
+	Allowed    int    `json:"allowed"`
+	Aborted    int    `json:"aborted"`
+	Blocked    int    `json:"blocked"`
+	Denied     int    `json:"denied"`
+	NoTerminal int    `json:"no_terminal"`
+	// Failed counts supervised commands that kubectl ran but exited non-zero.
+	Failed int `json:"failed"`
+	// Verbs are the most frequent verbs, most frequent first.
+	Verbs []VerbCount `json:"top_verbs"`
+}
+
+// VerbCount is how often a verb was run.
+type VerbCount struct {
+	Verb  string `json:"verb"`
+	Count int    `json:"count"`
+}
+
+// Summarize groups records by context, in context order.
+func Summarize(records []Record) []Summary {
+	byContext := map[string]*Summary{}
+	verbs := map[string]map[string]int{}
+	for _, r := range records {
+		s, ok := byContext[r.Context]
+		if !ok {
+			s = &Summary{Context: r.Context}
+			byContext[r.Context] = s
+			verbs[r.Context] = map[string]int{}
+		}
+		s.Total++
+		switch r.Outcome {
+		case OutcomeConfirmed:
+			s.Confirmed++
+		case OutcomeApproved:
+			s.Approved++
+		case OutcomeWarned:
+			s.Warned++
+		case OutcomeAllowed:
+			s.Allowed++
+		case OutcomeAborted:
+			s.Aborted++
+		case OutcomeBlocked:
+			s.Blocked++
+		case OutcomeDenied:
+			s.Denied++
+		case OutcomeNoTerminal:
+			s.NoTerminal++
+		}
+		if r.ExitCode != nil && *r.ExitCode != 0 {
+			s.Failed++
+		}
+		if verb := r.Verb(); verb != "" {
+			verbs[r.Context][verb]++
+		}
+	}
+
+	summaries := make([]Summary, 0, len(byContext))
+	for context, s := range byContext {
+		for verb, n := range verbs[context] {
+			s.Verbs = append(s.Verbs, VerbCount{Verb: verb, Count: n})
+		}
+		slices.SortFunc(s.Verbs, func(a, b VerbCount) int {
+			return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.Verb, b.Verb))
+		})
+		s.Verbs = s.Verbs[:min(len(s.Verbs), topVerbs)]
+		summaries = append(summaries, *s)
+	}
+	slices.SortFunc(summaries, func(a, b Summary) int { return cmp.Compare(a.Context, b.Context) })
+	return summaries
+}
+
+// WriteRecords writes records as a list in the given format.
+func WriteRecords(w io.Writer, records []Record, format Format) error {
+	if format == FormatJSON {
+		return writeJSON(w, nonNil(records))
+	}
+	headers := []string{"ID", "TIME", "USER", "CONTEXT", "NAMESPACE", "COMMAND", "DECISION", "OUTCOME", "EXIT"}
+	rows := make([][]string, len(records))
+	for i, r := range records {
+		exit := ""
+		if r.ExitCode != nil {
+			exit = strconv.Itoa(*r.ExitCode)
+		}
+		rows[i] = []string{
+			r.ID, r.Time.Local().Format(time.DateTime), r.User, r.Context, r.Namespace,
+			strings.Join(r.Args, " "), r.Decision, string(r.Outcome), exit,
+		}
+	}
+	return writeTable(w, headers, rows, format)
+}
+
+// WriteReport writes per-context summaries in the given format.
+func WriteReport(w io.Writer, summaries []Summary, format Format) error {
+	if format == FormatJSON {
+		return writeJSON(w, nonNil(summaries))
+	}
+	headers := []string{"CONTEXT", "TOTAL", "CONFIRMED", "APPROVED", "WARNED", "ALLOWED", "ABORTED", "BLOCKED", "DENIED", "NO TERMINAL", "FAILED", "TOP VERBS"}
+	rows := make([][]string, len(summaries))
+	for i, s := range summaries {
+		verbs := make([]string, len(s.Verbs))
+		for j, v := range s.Verbs {
+			verbs[j] = fmt.Sprintf("%s (%d)", v.Verb, v.Count)
+		}
+		rows[i] = []string{
+			s.Context, strconv.Itoa(s.Total), strconv.Itoa(s.Confirmed), strconv.Itoa(s.Approved),
+			strconv.Itoa(s.Warned), strconv.Itoa(s.Allowed), strconv.Itoa(s.Aborted), strconv.Itoa(s.Blocked),
+			strconv.Itoa(s.Denied), strconv.Itoa(s.NoTerminal), strconv.Itoa(s.Failed), strings.Join(verbs, ", "),
+		}
+	}
+	return writeTable(w, headers, rows, format)
+}
+
+// WriteRecord writes one record in full: indented JSON, or one field per
+// line as a table. CSV and markdown lay out lists of records, so they are
+// rejected.
+func WriteRecord(w io.Writer, r Record, format Format) error {
+	switch format {
+	case FormatJSON:
+		return writeJSON(w, r)
+	case FormatCSV, FormatMarkdown:
+		return fmt.Errorf("a single record can't be shown as %s (want table or json)", format)
+	}
+	namespace := r.Namespace
+	if r.AllNamespaces {
+		namespace = "all namespaces"
+	}
+	fields := []struct{ name, value string }{
+		{"ID", r.ID},
+		{"Time", r.Time.Local().Format(time.RFC3339)},
+		{"User", r.User},
+		{"Host", r.Host},
+		{"TTY", r.TTY},
+		{"Context", r.Context},
+		{"Cluster", r.Cluster},
+		{"Server", r.Server},
+		{"Namespace", namespace},
+		{"Command", strings.Join(r.Args, " ")},
+		{"Category", r.Category},
+		{"Risk", r.Risk},
+		{"Rule", r.Rule},
+		{"Mode", r.Mode},
+		{"Decision", r.Decision},
+		{"Outcome", string(r.Outcome)},
+		{"Approval", r.Approval},
+		{"Reason", r.Reason},
+		{"Error", r.Error},
+		{"Signal", r.Signal},
+	}
+	if r.ExitCode != nil {
+		fields = append(fields,
+			struct{ name, value string }{"Exit code", strconv.Itoa(*r.ExitCode)},
+			struct{ name, value string }{"Duration", (time.Duration(r.DurationMS) * time.Millisecond).String()})
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, f := range fields {
+		if f.value != "" {
+			fmt.Fprintf(tw, "%s:\t%s\n", f.name, f.value)
+		}
+	}
+	if len(r.Reasons) > 0 {
+		fmt.Fprintln(tw, "Reasons:")
+		for _, reason := range r.Reasons {
+			fmt.Fprintf(tw, "  - %s\n", reason)
+		}
+	}
+	return tw.Flush()
+}
+
+// writeTable renders rows as an aligned table, CSV or a Markdown table.
+func writeTable(w io.Writer, headers []string, rows [][]string, format Format) error {
+	switch format {
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(headers); err != nil {
+			return err
+		}
+		if err := cw.WriteAll(rows); err != nil {
+			return err
+		}
+		return cw.Error()
+
+	case FormatMarkdown:
+		cells := func(values []string) string {
+			escaped := make([]string, len(values))
+			for i, v := range values {
+				escaped[i] = strings.ReplaceAll(v, "|", `\|`)
+			}
+			return "| " + strings.Join(escaped, " | ") + " |\n"
+		}
+		separators := make([]string, len(headers))
+		for i := range separators {
+			separators[i] = "---"
+		}
+		out := cells(headers) + cells(separators)
+		for _, row := range rows {
+			out += cells(row)
+		}
+		_, err := io.WriteString(w, out)
+		return err
+
+	default:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(headers, "\t"))
+		for _, row := range rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+	}
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// nonNil makes an empty list encode as [] rather than null.
+func nonNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
@@ -0,0 +1,141 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testRecords() []Record {
+	exit := 1
+	at := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	return []Record{
+		{ID: "r1", Time: at, User: "alice", Context: "prod", Command: "delete", Args: []string{"delete", "pod", "a"}, Decision: "confirm", Outcome: OutcomeConfirmed},
+		{ID: "r2", Time: at, User: "alice", Context: "prod", Command: "delete", Args: []string{"delete", "pod", "b"}, Decision: "confirm", Outcome: OutcomeAborted},
+		{ID: "r3", Time: at, User: "bob", Context: "prod", Command: "scale", Args: []string{"scale", "deploy/web", "--replicas=0"}, Decision: "typed", Outcome: OutcomeApproved, ExitCode: &exit},
+		{ID: "r4", Time: at, User: "bob", Context: "prod", Command: "apply", Args: []string{"apply", "-f", "x|y.yaml"}, Decision: "warn", Outcome: OutcomeWarned},
+		{ID: "r5", Time: at, User: "bob", Context: "prod", Command: "drain", Args: []string{"drain", "node-1"}, Decision: "block", Outcome: OutcomeBlocked},
+		{ID: "r6", Time: at, User: "carol", Context: "dev", Command: "delete", Args: []string{"delete", "ns", "tmp"}, Decision: "confirm", Outcome: OutcomeNoTerminal},
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	summaries := Summarize(testRecords())
+	if len(summaries) != 2 || summaries[0].Context != "dev" || summaries[1].Context != "prod" {
+		t.Fatalf("Summarize() = %+v, want dev then prod", summaries)
+	}
+
+	dev, prod := summaries[0], summaries[1]
+	if dev.Total != 1 || dev.NoTerminal != 1 {
+		t.Errorf("dev = %+v, want 1 record without a terminal", dev)
+	}
+	if prod.Total != 5 || prod.Confirmed != 1 || prod.Aborted != 1 || prod.Approved != 1 ||
+		prod.Warned != 1 || prod.Blocked != 1 || prod.Failed != 1 {
+		t.Errorf("prod = %+v, want one of each outcome and one failure", prod)
+	}
+	// delete leads; the ties after it sort by name and stop at three.
+	want := []VerbCount{{"delete", 2}, {"apply", 1}, {"drain", 1}}
+	if len(prod.Verbs) != len(want) {
+		t.Fatalf("prod verbs = %+v, want %+v", prod.Verbs, want)
+	}
+	for i := range want {
+		if prod.Verbs[i] != want[i] {
+			t.Errorf("prod verbs = %+v, want %+v", prod.Verbs, want)
+			break
+		}
+	}
+}
+
+func TestWriteRecords(t *testing.T) {
+	records := testRecords()
+
+	var buf bytes.Buffer
+	if err := WriteRecords(&buf, records, FormatCSV); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != len(records)+1 || !strings.HasPrefix(lines[0], "ID,TIME,USER") {
+		t.Errorf("CSV = %q, want a header and %d rows", buf.String(), len(records))
+	}
+	if !strings.HasSuffix(lines[3], ",typed,approved,1") {
+		t.Errorf("CSV row %q, want it to end with the decision, outcome and exit code", lines[3])
+	}
+
+	buf.Reset()
+	if err := WriteRecords(&buf, records, FormatMarkdown); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "| --- |") || !strings.Contains(buf.String(), `x\|y.yaml`) {
+		t.Errorf("Markdown = %s, want a separator row and escaped pipes", buf.String())
+	}
+
+	buf.Reset()
+	if err := WriteRecords(&buf, nil, FormatJSON); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(buf.String()); got != "[]" {
+		t.Errorf("JSON of no records = %s, want []", got)
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	summaries := Summarize(testRecords())
+
+	var buf bytes.Buffer
+	if err := WriteReport(&buf, summaries, FormatMarkdown); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "| prod | 5 | 1 | 1 | 1 | 0 | 1 | 1 | 0 | 0 | 1 | delete (2), apply (1), drain (1) |") {
+		t.Errorf("Markdown report = %s", buf.String())
+	}
+
+	buf.Reset()
+	if err := WriteReport(&buf, summaries, FormatJSON); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []Summary
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 2 || decoded[1].Verbs[0].Verb != "delete" {
+		t.Errorf("JSON report = %s", buf.String())
+	}
+}
+
+func TestWriteRecord(t *testing.T) {
+	r := testRecords()[2]
+	r.Reasons = []string{"scale to zero is high risk"}
+
+	var buf bytes.Buffer
+	if err := WriteRecord(&buf, r, FormatTable); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"ID:", "r3", "Exit code:", "Reasons:\n  - scale to zero is high risk"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("WriteRecord() = %s, want %q", buf.String(), want)
+		}
+	}
+	if strings.Contains(buf.String(), "Signal:") {
+		t.Errorf("WriteRecord() = %s, want empty fields left out", buf.String())
+	}
+
+	for _, format := range []Format{FormatCSV, FormatMarkdown} {
+		buf.Reset()
+		if err := WriteRecord(&buf, r, format); err == nil || buf.Len() > 0 {
+			t.Errorf("WriteRecord(%s) = (%q, %v), want an error and no output", format, buf.String(), err)
+		}
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for in, want := range map[string]Format{"table": FormatTable, "json": FormatJSON, "csv": FormatCSV, "markdown": FormatMarkdown, "md": FormatMarkdown} {
+		if got, err := ParseFormat(in); err != nil || got != want {
+			t.Errorf("ParseFormat(%q) = (%s, %v), want %s", in, got, err, want)
+		}
+	}
+	if _, err := ParseFormat("yaml"); err == nil {
+		t.Error("ParseFormat(yaml) succeeded, want an error")
+	}
+}
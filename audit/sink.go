@@ -0,0 +1,478 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cameronlockhart/kubectl-guard/config"
+)
+
+// Defaults for sinks that leave fields unset.
+const (
+	defaultSinkTimeout = 2 * time.Second
+	spoolDirName       = "spool"
+)
+
+// HTTP sinks retry a record that failed temporarily a few times, doubling
+// the wait between attempts, but never past the sink's timeout.
+const (
+	deliveryAttempts = 3
+	retryBackoff     = 100 * time.Millisecond
+)
+
+// Sink forwards audit records somewhere other than the local log.
+type Sink interface {
+	Send(r Record) error
+	// String names the sink in errors.
+	String() string
+}
+
+// newSink returns the sink described by cfg. HTTP sinks without a spool
+// directory spool under spoolRoot.
+func newSink(cfg config.SinkConfig, spoolRoot string) (Sink, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("audit sink %s: %w", cfg, err)
+	}
+	timeout := defaultSinkTimeout
+	if cfg.TimeoutSeconds > 0 {
+		timeout = time.Duration(cfg.TimeoutSeconds) * time.Second
+	}
+	if cfg.Type == config.SinkSyslog {
+		return &syslogSink{name: cfg.String(), network: cfg.Network, address: cfg.Address, timeout: timeout}, nil
+	}
+
+	s := &httpSink{
+		name:    cfg.String(),
+		url:     cfg.URL,
+		headers: cfg.Headers,
+		client:  &http.Client{},
+		timeout: timeout,
+		encode:  encodeWebhook,
+	}
+	if cfg.Type == config.SinkOTLP {
+		s.encode = encodeOTLP
+	}
+	if cfg.Spool != "" {
+		spool, err := resolvePath(cfg.Spool)
+		if err != nil {
+			return nil, err
+		}
+		s.spool = spool
+	} else {
+		// Name the default spool after the destination, so each sink keeps
+		// its own however the config is reordered.
+		sum := sha256.Sum256([]byte(cfg.URL))
+		s.spool = filepath.Join(spoolRoot, fmt.Sprintf("%s-%s", cfg.Type, hex.EncodeToString(sum[:4])))
+	}
+	return s, nil
+}
+
+// refused reports whether the outcome kept the command from running, which
+// sinks flag with a higher severity.
+func refused(o Outcome) bool {
+	switch o {
+	case OutcomeAborted, OutcomeBlocked, OutcomeDenied, OutcomeNoTerminal:
+		return true
+	}
+	return false
+}
+
+// Syslog facility and severities, from RFC 5424.
+const (
+	syslogFacilityAuth    = 4
+	syslogSeverityWarning = 4
+	syslogSeverityNotice  = 5
+	// syslogSDID names the structured data element. 32473 is the private
+	// enterprise number RFC 5612 reserves for documentation.
+	syslogSDID = "kubectl-guard@32473"
+	appName    = "kubectl-guard"
+)
+
+// syslogSink sends RFC 5424 messages to a syslog server or socket.
+type syslogSink struct {
+	name    string
+	network string
+	address string
+	timeout time.Duration
+}
+
+func (s *syslogSink) String() string { return s.name }
+
+// Send delivers one message per connection. Stream connections use the
+// octet-counting framing of RFC 6587; datagrams carry the bare message.
+func (s *syslogSink) Send(r Record) error {
+	msg, err := syslogMessage(r)
+	if err != nil {
+		return err
+	}
+
+	var conn net.Conn
+	if s.network == "unix" {
+		// Local syslog daemons listen on datagram sockets such as /dev/log,
+		// but some use stream sockets.
+		conn, err = net.DialTimeout("unixgram", s.address, s.timeout)
+		if err != nil {
+			conn, err = net.DialTimeout("unix", s.address, s.timeout)
+		}
+	} else {
+		conn, err = net.DialTimeout(s.network, s.address, s.timeout)
+	}
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(s.timeout))
+
+	switch conn.LocalAddr().Network() {
+	case "tcp", "unix":
+		msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+	}
+	_, err = conn.Write(msg)
+	return err
+}
+
+// syslogMessage formats a record as an RFC 5424 message: a header, the
+// record's key fields as structured data, and the whole record as JSON.
+func syslogMessage(r Record) ([]byte, error) {
+	body, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	severity := syslogSeverityNotice
+	if refused(r.Outcome) {
+		severity = syslogSeverityWarning
+	}
+
+	var sd strings.Builder
+	sd.WriteString("[" + syslogSDID)
+	for _, param := range []struct{ name, value string }{
+		{"id", r.ID},
+		{"user", r.User},
+		{"context", r.Context},
+		{"namespace", r.Namespace},
+		{"command", r.Command},
+		{"decision", r.Decision},
+		{"outcome", string(r.Outcome)},
+	} {
+		if param.value != "" {
+			fmt.Fprintf(&sd, ` %s="%s"`, param.name, sdEscaper.Replace(param.value))
+		}
+	}
+	sd.WriteString("]")
+
+	header := fmt.Sprintf("<%d>1 %s %s %s %d %s",
+		syslogFacilityAuth*8+severity,
+		r.Time.UTC().Format("2006-01-02T15:04:05.000000Z07:00"),
+		headerField(r.Host, 255),
+		appName,
+		os.Getpid(),
+		headerField(string(r.Outcome), 32))
+	return []byte(header + " " + sd.String() + " " + string(body)), nil
+}
+
+// sdEscaper escapes structured data parameter values.
+var sdEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+// headerField makes s a valid RFC 5424 header field: printable ASCII
+// without spaces, at most max long, or "-" for none.
+func headerField(s string, max int) string {
+	s = strings.Map(func(r rune) rune {
+		if r < '!' || r > '~' {
+			return '_'
+		}
+		return r
+	}, s)
+	if s == "" {
+		return "-"
+	}
+	return s[:min(len(s), max)]
+}
+
+// httpSink POSTs records to a webhook or OTLP collector. Every record is
+// spooled to disk first, then the spool is delivered oldest first within a
+// short timeout, retrying briefly; whatever isn't delivered in time waits
+// for the next record. kubectl never waits longer than the timeout.
+type httpSink struct {
+	name    string
+	url     string
+	headers map[string]string
+	client  *http.Client
+	// timeout bounds each Send's delivery of the spool.
+	timeout time.Duration
+	spool   string
+	encode  func(Record) ([]byte, error)
+}
+
+func (s *httpSink) String() string { return s.name }
+
+// permanentError is a delivery failure that retrying won't fix, such as a
+// 400 response.
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Send spools r behind any records already waiting, then delivers what it
+// can of the spool before the timeout, so records arrive in order. It
+// returns an error only when r couldn't be spooled or the endpoint
+// rejected a record outright.
+func (s *httpSink) Send(r Record) error {
+	deadline := time.Now().Add(s.timeout)
+	if err := os.MkdirAll(s.spool, 0700); err != nil {
+		return err
+	}
+	if err := s.enqueue(r); err != nil {
+		return err
+	}
+	// Concurrent kubectl-guard processes would otherwise deliver the same
+	// spooled records twice. Another holder is bounded by its own timeout.
+	unlock, err := lockFile(filepath.Join(s.spool, ".lock"))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	return s.flush(ctx)
+}
+
+// deliver POSTs r, retrying temporary failures up to deliveryAttempts
+// times while ctx leaves time for the backoff.
+func (s *httpSink) deliver(ctx context.Context, r Record) error {
+	backoff := retryBackoff
+	for attempt := 1; ; attempt++ {
+		err := s.post(ctx, r)
+		var permanent *permanentError
+		if err == nil || errors.As(err, &permanent) || attempt == deliveryAttempts {
+			return err
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < backoff {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// post POSTs r once.
+func (s *httpSink) post(ctx context.Context, r Record) error {
+	body, err := s.encode(r)
+	if err != nil {
+		return &permanentError{err}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return &permanentError{err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", appName)
+	for key, value := range s.headers {
+		req.Header.Set(key, os.ExpandEnv(value))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusRequestTimeout,
+		resp.StatusCode == http.StatusTooManyRequests,
+		resp.StatusCode >= 500:
+		return fmt.Errorf("%s answered %s", s.url, resp.Status)
+	default:
+		return &permanentError{fmt.Errorf("%s rejected record %s with %s", s.url, r.ID, resp.Status)}
+	}
+}
+
+// flush delivers spooled records oldest first and stops at the first that
+// still fails after its retries or when ctx is done, leaving the rest for
+// the next flush. Records the endpoint rejects outright are renamed with a
+// .rejected suffix, left for inspection and reported.
+func (s *httpSink) flush(ctx context.Context) error {
+	entries, err := os.ReadDir(s.spool)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// ReadDir sorts by name, and names start with the record's time.
+	var rejected []error
+	for _, e := range entries {
+		if ctx.Err() != nil {
+			break
+		}
+		if !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		path := filepath.Join(s.spool, e.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return errors.Join(append(rejected, err)...)
+		}
+		var r Record
+		if err = json.Unmarshal(data, &r); err == nil {
+			err = s.deliver(ctx, r)
+		} else {
+			err = &permanentError{fmt.Errorf("%s: %w", path, err)}
+		}
+		var permanent *permanentError
+		switch {
+		case err == nil:
+			if err := os.Remove(path); err != nil {
+				return errors.Join(append(rejected, err)...)
+			}
+		case errors.As(err, &permanent):
+			rejected = append(rejected, err)
+			if err := os.Rename(path, path+".rejected"); err != nil {
+				return errors.Join(append(rejected, err)...)
+			}
+		default:
+			return errors.Join(rejected...)
+		}
+	}
+	return errors.Join(rejected...)
+}
+
+// enqueue writes r to the spool, named so that the spool sorts oldest
+// first.
+func (s *httpSink) enqueue(r Record) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	name := filepath.Join(s.spool, fmt.Sprintf("%020d-%s.json", r.Time.UnixNano(), r.ID))
+	// Write under a temporary name so flush never reads half a record.
+	if err := os.WriteFile(name+".tmp", data, 0600); err != nil {
+		return fmt.Errorf("could not spool the record: %w", err)
+	}
+	return os.Rename(name+".tmp", name)
+}
+
+// encodeWebhook sends the record as it appears in the audit log.
+func encodeWebhook(r Record) ([]byte, error) {
+	return json.Marshal(r)
+}
+
+// OTLP/HTTP log types, covering the subset of ExportLogsServiceRequest
+// that a record needs, in its JSON encoding.
+type (
+	otlpRequest struct {
+		ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+	}
+	otlpResourceLogs struct {
+		Resource  otlpResource    `json:"resource"`
+		ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+	}
+	otlpResource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	}
+	otlpScopeLogs struct {
+		Scope      otlpScope       `json:"scope"`
+		LogRecords []otlpLogRecord `json:"logRecords"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpLogRecord struct {
+		TimeUnixNano         string          `json:"timeUnixNano"`
+		ObservedTimeUnixNano string          `json:"observedTimeUnixNano"`
+		SeverityNumber       int             `json:"severityNumber"`
+		SeverityText         string          `json:"severityText"`
+		Body                 otlpValue       `json:"body"`
+		Attributes           []otlpAttribute `json:"attributes"`
+	}
+	otlpAttribute struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+	otlpValue struct {
+		StringValue string `json:"stringValue"`
+	}
+)
+
+// OTLP severity numbers.
+const (
+	otlpSeverityInfo = 9
+	otlpSeverityWarn = 13
+)
+
+// encodeOTLP sends the record as one OTLP log: the record's JSON is the
+// body, and its key fields are attributes to filter on.
+func encodeOTLP(r Record) ([]byte, error) {
+	body, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	severity, severityText := otlpSeverityInfo, "INFO"
+	if refused(r.Outcome) {
+		severity, severityText = otlpSeverityWarn, "WARN"
+	}
+
+	var attrs []otlpAttribute
+	add := func(key, value string) {
+		if value != "" {
+			attrs = append(attrs, otlpAttribute{Key: key, Value: otlpValue{StringValue: value}})
+		}
+	}
+	add("user.name", r.User)
+	add("kubectl_guard.id", r.ID)
+	add("kubectl_guard.context", r.Context)
+	add("kubectl_guard.cluster", r.Cluster)
+	add("kubectl_guard.namespace", r.Namespace)
+	add("kubectl_guard.command", r.Command)
+	add("kubectl_guard.args", strings.Join(r.Args, " "))
+	add("kubectl_guard.risk", r.Risk)
+	add("kubectl_guard.rule", r.Rule)
+	add("kubectl_guard.decision", r.Decision)
+	add("kubectl_guard.outcome", string(r.Outcome))
+	add("kubectl_guard.reason", r.Reason)
+	if r.ExitCode != nil {
+		add("kubectl_guard.exit_code", strconv.Itoa(*r.ExitCode))
+	}
+
+	resource := []otlpAttribute{{Key: "service.name", Value: otlpValue{StringValue: appName}}}
+	if r.Host != "" {
+		resource = append(resource, otlpAttribute{Key: "host.name", Value: otlpValue{StringValue: r.Host}})
+	}
+	now := strconv.FormatInt(time.Now().UnixNano(), 10)
+	return json.Marshal(otlpRequest{ResourceLogs: []otlpResourceLogs{{
+		Resource: otlpResource{Attributes: resource},
+		ScopeLogs: []otlpScopeLogs{{
+			Scope: otlpScope{Name: appName},
+			LogRecords: []otlpLogRecord{{
+				TimeUnixNano:         strconv.FormatInt(r.Time.UnixNano(), 10),
+				ObservedTimeUnixNano: now,
+				SeverityNumber:       severity,
+				SeverityText:         severityText,
+				Body:                 otlpValue{StringValue: string(body)},
+				Attributes:           attrs,
+			}},
+		}},
+	}}})
+}
@@ -0,0 +1,465 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cameronlockhart/kubectl-guard/config"
+)
+
+func sinkRecord(id string, outcome Outcome) Record {
+	return Record{
+		ID:       id,
+		Time:     time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC),
+		User:     "alice",
+		Host:     "laptop",
+		Context:  "prod",
+		Args:     []string{"delete", "pod", "web"},
+		Command:  "delete",
+		Decision: "confirm",
+		Outcome:  outcome,
+	}
+}
+
+func TestSyslogMessage(t *testing.T) {
+	r := sinkRecord("abc", OutcomeConfirmed)
+	r.Namespace = `pay"ments]`
+
+	msg, err := syslogMessage(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, rest, _ := strings.Cut(string(msg), " [")
+	// auth facility (4) and notice severity (5).
+	if want := "<37>1 2026-10-01T12:00:00.000000Z laptop kubectl-guard "; !strings.HasPrefix(header, want) {
+		t.Errorf("header = %q, want prefix %q", header, want)
+	}
+	if !strings.HasSuffix(header, " confirmed") {
+		t.Errorf("header = %q, want the outcome as MSGID", header)
+	}
+	if !strings.Contains(rest, `namespace="pay\"ments\]"`) {
+		t.Errorf("structured data = %q, want escaped namespace", rest)
+	}
+	_, body, _ := strings.Cut(rest, "] ")
+	var decoded Record
+	if err := json.Unmarshal([]byte(body), &decoded); err != nil || decoded.ID != "abc" {
+		t.Errorf("message body %q is not the record: %v", body, err)
+	}
+
+	msg, _ = syslogMessage(sinkRecord("abc", OutcomeAborted))
+	if !strings.HasPrefix(string(msg), "<36>1 ") {
+		t.Errorf("aborted message = %.10q, want warning severity <36>", msg)
+	}
+
+	r.Host = ""
+	msg, _ = syslogMessage(r)
+	if !strings.Contains(string(msg), "Z - kubectl-guard ") {
+		t.Errorf("message = %.60q, want - for a missing host", msg)
+	}
+}
+
+func TestSyslogSink(t *testing.T) {
+	t.Run("udp", func(t *testing.T) {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		sendSyslog(t, "udp", conn.LocalAddr().String())
+		buf := make([]byte, 64<<10)
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(string(buf[:n]), "<37>1 ") {
+			t.Errorf("datagram = %.20q, want a bare RFC 5424 message", buf[:n])
+		}
+	})
+
+	t.Run("unixgram", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "log.sock")
+		conn, err := net.ListenPacket("unixgram", path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		sendSyslog(t, "unix", path)
+		buf := make([]byte, 64<<10)
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(string(buf[:n]), "<37>1 ") {
+			t.Errorf("datagram = %.20q, want a bare RFC 5424 message", buf[:n])
+		}
+	})
+
+	for _, network := range []string{"tcp", "unix"} {
+		t.Run(network+" stream", func(t *testing.T) {
+			address := "127.0.0.1:0"
+			if network == "unix" {
+				address = filepath.Join(t.TempDir(), "log.sock")
+			}
+			ln, err := net.Listen(network, address)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ln.Close()
+
+			got := make(chan string, 1)
+			go func() {
+				conn, err := ln.Accept()
+				if err != nil {
+					got <- err.Error()
+					return
+				}
+				defer conn.Close()
+				data, _ := io.ReadAll(bufio.NewReader(conn))
+				got <- string(data)
+			}()
+
+			sendSyslog(t, network, ln.Addr().String())
+			frame := <-got
+			length, msg, _ := strings.Cut(frame, " ")
+			if length != strconv.Itoa(len(msg)) || !strings.HasPrefix(msg, "<37>1 ") {
+				t.Errorf("frame = %.30q, want an octet-counted RFC 5424 message", frame)
+			}
+		})
+	}
+}
+
+func sendSyslog(t *testing.T, network, address string) {
+	t.Helper()
+	s, err := newSink(config.SinkConfig{Type: config.SinkSyslog, Network: network, Address: address}, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Send(sinkRecord("abc", OutcomeConfirmed)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// collector is a local HTTP endpoint that answers with queued status codes,
+// then 200, and keeps the bodies it accepted.
+type collector struct {
+	mu       sync.Mutex
+	statuses []int
+	bodies   [][]byte
+	headers  []http.Header
+	requests int
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requests++
+	if len(c.statuses) > 0 {
+		status := c.statuses[0]
+		c.statuses = c.statuses[1:]
+		w.WriteHeader(status)
+		return
+	}
+	c.bodies = append(c.bodies, body)
+	c.headers = append(c.headers, r.Header)
+}
+
+// ids decodes the webhook bodies the collector accepted.
+func (c *collector) ids(t *testing.T) []string {
+	t.Helper()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var ids []string
+	for _, body := range c.bodies {
+		var r Record
+		if err := json.Unmarshal(body, &r); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, r.ID)
+	}
+	return ids
+}
+
+func webhookSink(t *testing.T, url string) *httpSink {
+	t.Helper()
+	s, err := newSink(config.SinkConfig{
+		Type:    config.SinkWebhook,
+		URL:     url,
+		Headers: map[string]string{"Authorization": "Bearer $AUDIT_TOKEN"},
+	}, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s.(*httpSink)
+}
+
+func spooled(t *testing.T, s *httpSink, suffix string) []string {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(s.spool, "*"+suffix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return matches
+}
+
+func TestWebhookSinkDelivers(t *testing.T) {
+	t.Setenv("AUDIT_TOKEN", "s3cret")
+	c := &collector{}
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	s := webhookSink(t, srv.URL)
+	if err := s.Send(sinkRecord("r1", OutcomeConfirmed)); err != nil {
+		t.Fatal(err)
+	}
+	if c.requests != 1 || strings.Join(c.ids(t), ",") != "r1" {
+		t.Errorf("collector saw %d requests and accepted %q, want 1 and r1", c.requests, c.ids(t))
+	}
+	if got := c.headers[0].Get("Authorization"); got != "Bearer s3cret" {
+		t.Errorf("Authorization = %q, want the token expanded from the environment", got)
+	}
+	if len(spooled(t, s, ".json")) != 0 {
+		t.Error("a delivered record was left in the spool")
+	}
+}
+
+func TestWebhookSinkSpools(t *testing.T) {
+	c := &collector{}
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+	s := webhookSink(t, srv.URL)
+
+	// Offline: each Send tries the oldest record a few times, then gives
+	// up, so both records wait in the spool.
+	c.statuses = slices.Repeat([]int{503}, 2*deliveryAttempts)
+	for _, id := range []string{"r1", "r2"} {
+		if err := s.Send(sinkRecord(id, OutcomeConfirmed)); err != nil {
+			t.Fatalf("Send(%s) error = %v, want the record spooled", id, err)
+		}
+	}
+	if n := len(spooled(t, s, ".json")); n != 2 {
+		t.Fatalf("%d records spooled, want 2", n)
+	}
+	if c.requests != 2*deliveryAttempts {
+		t.Errorf("collector saw %d requests, want %d per Send", c.requests, deliveryAttempts)
+	}
+
+	// Back online: the spool drains in order before the new record.
+	c.statuses = nil
+	r3 := sinkRecord("r3", OutcomeConfirmed)
+	if err := s.Send(r3); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(c.ids(t), ","); got != "r1,r2,r3" {
+		t.Errorf("delivered %s, want r1,r2,r3", got)
+	}
+	if n := len(spooled(t, s, ".json")); n != 0 {
+		t.Errorf("%d records still spooled, want none", n)
+	}
+}
+
+func TestWebhookSinkRetries(t *testing.T) {
+	c := &collector{statuses: []int{503, http.StatusTooManyRequests}}
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	s := webhookSink(t, srv.URL)
+	if err := s.Send(sinkRecord("r1", OutcomeConfirmed)); err != nil {
+		t.Fatal(err)
+	}
+	if c.requests != 3 || strings.Join(c.ids(t), ",") != "r1" {
+		t.Errorf("collector saw %d requests and accepted %q, want r1 on the third", c.requests, c.ids(t))
+	}
+	if n := len(spooled(t, s, ".json")); n != 0 {
+		t.Errorf("%d records still spooled, want none", n)
+	}
+
+	// Retries stop in time for the timeout.
+	c.statuses = slices.Repeat([]int{503}, deliveryAttempts)
+	c.requests = 0
+	s.timeout = 150 * time.Millisecond
+	if err := s.Send(sinkRecord("r2", OutcomeConfirmed)); err != nil {
+		t.Fatal(err)
+	}
+	if c.requests != 2 {
+		t.Errorf("collector saw %d requests, want the third skipped for lack of time", c.requests)
+	}
+}
+
+func TestWebhookSinkUnreachable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+
+	s := webhookSink(t, url)
+	if err := s.Send(sinkRecord("r1", OutcomeConfirmed)); err != nil {
+		t.Fatalf("Send() error = %v, want the record spooled", err)
+	}
+	if n := len(spooled(t, s, ".json")); n != 1 {
+		t.Errorf("%d records spooled, want 1", n)
+	}
+}
+
+func TestWebhookSinkTimeout(t *testing.T) {
+	// A collector that never answers holds kubectl up for the timeout
+	// only, and the record waits in the spool.
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	s := webhookSink(t, srv.URL)
+	s.timeout = 50 * time.Millisecond
+	start := time.Now()
+	if err := s.Send(sinkRecord("r1", OutcomeConfirmed)); err != nil {
+		t.Fatalf("Send() error = %v, want the record spooled", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Send() took %s, want it bounded by the timeout", elapsed)
+	}
+	if n := len(spooled(t, s, ".json")); n != 1 {
+		t.Errorf("%d records spooled, want 1", n)
+	}
+}
+
+func TestWebhookSinkRejected(t *testing.T) {
+	c := &collector{statuses: []int{http.StatusBadRequest}}
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	s := webhookSink(t, srv.URL)
+	if err := s.Send(sinkRecord("r1", OutcomeConfirmed)); err == nil || !strings.Contains(err.Error(), "400") {
+		t.Errorf("Send() error = %v, want the 400 reported", err)
+	}
+	if c.requests != 1 || len(spooled(t, s, ".json")) != 0 {
+		t.Errorf("a rejected record was retried or left to resend (%d requests)", c.requests)
+	}
+
+	// A rejected record is set aside, not resent, and doesn't hold up the
+	// ones behind it.
+	if err := s.Send(sinkRecord("r2", OutcomeConfirmed)); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(c.ids(t), ","); got != "r2" {
+		t.Errorf("delivered %s, want r2", got)
+	}
+	if n := len(spooled(t, s, ".rejected")); n != 1 {
+		t.Errorf("%d rejected records kept, want 1", n)
+	}
+}
+
+func TestOTLPSink(t *testing.T) {
+	c := &collector{}
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	s, err := newSink(config.SinkConfig{Type: config.SinkOTLP, URL: srv.URL + "/v1/logs"}, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	exit := 0
+	r := sinkRecord("r1", OutcomeBlocked)
+	r.ExitCode = &exit
+	if err := s.Send(r); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(c.bodies) != 1 {
+		t.Fatalf("collector accepted %d requests, want 1", len(c.bodies))
+	}
+	var req otlpRequest
+	if err := json.Unmarshal(c.bodies[0], &req); err != nil {
+		t.Fatal(err)
+	}
+	if len(req.ResourceLogs) != 1 || len(req.ResourceLogs[0].ScopeLogs) != 1 || len(req.ResourceLogs[0].ScopeLogs[0].LogRecords) != 1 {
+		t.Fatalf("request = %s, want one log record", c.bodies[0])
+	}
+	resource := attributes(req.ResourceLogs[0].Resource.Attributes)
+	if resource["service.name"] != "kubectl-guard" || resource["host.name"] != "laptop" {
+		t.Errorf("resource attributes = %v", resource)
+	}
+	log := req.ResourceLogs[0].ScopeLogs[0].LogRecords[0]
+	if log.SeverityNumber != otlpSeverityWarn || log.TimeUnixNano != strconv.FormatInt(r.Time.UnixNano(), 10) {
+		t.Errorf("log record = %+v, want WARN at the record's time", log)
+	}
+	attrs := attributes(log.Attributes)
+	for key, want := range map[string]string{
+		"user.name":               "alice",
+		"kubectl_guard.context":   "prod",
+		"kubectl_guard.outcome":   "blocked",
+		"kubectl_guard.args":      "delete pod web",
+		"kubectl_guard.exit_code": "0",
+	} {
+		if attrs[key] != want {
+			t.Errorf("attribute %s = %q, want %q", key, attrs[key], want)
+		}
+	}
+	var body Record
+	if err := json.Unmarshal([]byte(log.Body.StringValue), &body); err != nil || body.ID != "r1" {
+		t.Errorf("body = %q, want the record", log.Body.StringValue)
+	}
+}
+
+func attributes(attrs []otlpAttribute) map[string]string {
+	m := map[string]string{}
+	for _, a := range attrs {
+		m[a.Key] = a.Value.StringValue
+	}
+	return m
+}
+
+func TestAppendForwards(t *testing.T) {
+	c := &collector{}
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	l, err := Open(config.AuditConfig{
+		Path:  filepath.Join(dir, "audit.jsonl"),
+		Sinks: []config.SinkConfig{{Type: config.SinkWebhook, URL: srv.URL}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Append(sinkRecord("r1", OutcomeConfirmed)); err != nil {
+		t.Fatal(err)
+	}
+	var forwarded Record
+	if err := json.Unmarshal(c.bodies[0], &forwarded); err != nil || forwarded.Hash == "" {
+		t.Errorf("forwarded %s, want the chained record", c.bodies[0])
+	}
+	if _, err := os.Stat(filepath.Join(dir, spoolDirName)); err != nil {
+		t.Errorf("default spool is not beside the log: %v", err)
+	}
+
+	// A record that can't be written locally still reaches the sink.
+	l.path = filepath.Join(dir, "audit.jsonl", "not-a-dir")
+	err = l.Append(sinkRecord("r2", OutcomeConfirmed))
+	if err == nil || !strings.Contains(err.Error(), "could not write the audit log") {
+		t.Errorf("Append() error = %v, want the local write failure", err)
+	}
+	if got := strings.Join(c.ids(t), ","); got != "r1,r2" {
+		t.Errorf("forwarded %s, want r1,r2", got)
+	}
+}
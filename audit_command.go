@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/cameronlockhart/kubectl-guard/audit"
+	"github.com/spf13/cobra"
+)
+
+func runAuditCommand() error {
+	rootCmd := &cobra.Command{
+		Use:   "audit",
+		Short: "Read the audit log",
+	}
+
+	var (
+		filter       audit.Filter
+		since, until string
+		output       string
+	)
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List audit records, oldest first",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, err := audit.ParseFormat(output)
+			if err != nil {
+				return err
+			}
+			if err := parseTimeRange(&filter, since, until); err != nil {
+				return err
+			}
+			records, err := readAuditLog()
+			if err != nil {
+				return err
+			}
+			return audit.WriteRecords(os.Stdout, filter.Select(records), format)
+		},
+	}
+	listCmd.Flags().StringVar(&filter.Context, "context", "", "only records for contexts matching this glob")
+	listCmd.Flags().StringVar(&filter.User, "user", "", "only records by this OS user")
+	listCmd.Flags().StringVar(&filter.Verb, "verb", "", `only records for this verb, e.g. "delete"`)
+	listCmd.Flags().StringVar(&filter.Decision, "decision", "", "only records with this decision or outcome, e.g. block or aborted")
+	listCmd.Flags().StringVar(&since, "since", "", "only records since a time: 24h, 7d, 2006-01-02 or RFC 3339")
+	listCmd.Flags().StringVar(&until, "until", "", "only records until a time, in the same forms as --since")
+	listCmd.Flags().StringVarP(&output, "output", "o", "table", "output format: table, json, csv or markdown")
+	rootCmd.AddCommand(listCmd)
+
+	var showOutput string
+	showCmd := &cobra.Command{
+		Use:   "show <id>",
+		Short: "Show one audit record in full",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, err := audit.ParseFormat(showOutput)
+			if err != nil {
+				return err
+			}
+			records, err := readAuditLog()
+			if err != nil {
+				return err
+			}
+			r, err := audit.Find(records, args[0])
+			if err != nil {
+				return err
+			}
+			return audit.WriteRecord(os.Stdout, r, format)
+		},
+	}
+	showCmd.Flags().StringVarP(&showOutput, "output", "o", "table", "output format: table or json")
+	rootCmd.AddCommand(showCmd)
+
+	var (
+		reportFilter             audit.Filter
+		reportSince, reportUntil string
+		reportOutput             string
+	)
+	reportCmd := &cobra.Command{
+		Use:   "report",
+		Short: "Summarize decisions per context",
+		Long: "Summarize decisions per context: confirmations, approvals, aborts, blocks\n" +
+			"and the most frequent verbs. Markdown output pastes into a postmortem.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, err := audit.ParseFormat(reportOutput)
+			if err != nil {
+				return err
+			}
+			if err := parseTimeRange(&reportFilter, reportSince, reportUntil); err != nil {
+				return err
+			}
+			records, err := readAuditLog()
+			if err != nil {
+				return err
+			}
+			return audit.WriteReport(os.Stdout, audit.Summarize(reportFilter.Select(records)), format)
+		},
+	}
+	reportCmd.Flags().StringVar(&reportFilter.Context, "context", "", "only contexts matching this glob")
+	reportCmd.Flags().StringVar(&reportFilter.User, "user", "", "only records by this OS user")
+	reportCmd.Flags().StringVar(&reportSince, "since", "", "only records since a time: 24h, 7d, 2006-01-02 or RFC 3339")
+	reportCmd.Flags().StringVar(&reportUntil, "until", "", "only records until a time, in the same forms as --since")
+	reportCmd.Flags().StringVarP(&reportOutput, "output", "o", "table", "output format: table, json, csv or markdown")
+	rootCmd.AddCommand(reportCmd)
+
+	verifyCmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Check the audit log's hash chain",
+		Long: "Walk the audit log, rotated files first, and report the first truncated\n" +
+			"tail, modified entry or broken link. With audit.hmac_key_file set, every\n" +
+			"record must carry a valid HMAC. Records without a hash are reported as\n" +
+			"unverified, and fail once a key is set or records have rotated out.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			log, err := audit.OpenConfigured()
+			if err != nil {
+				return err
+			}
+			if log == nil {
+				return fmt.Errorf("the audit log is disabled")
+			}
+			v, err := log.Verify()
+			if err != nil {
+				return err
+			}
+			files := "files"
+			if v.Files == 1 {
+				files = "file"
+			}
+			fmt.Printf("✓ %d records in %d %s verified\n", v.Records-v.Unchained, v.Files, files)
+			if v.Unchained > 0 {
+				fmt.Printf("⚠️  %d records at the start of the log have no hash and were NOT verified.\n", v.Unchained)
+				fmt.Println("   They may predate hash chaining, or have been stripped and altered.")
+				fmt.Println("   Set audit.hmac_key_file so that stripped records fail verification.")
+			}
+			if v.Signed > 0 {
+				fmt.Printf("  %d HMACs checked\n", v.Signed)
+			}
+			if v.Unsigned > 0 {
+				fmt.Printf("  %d records carry an HMAC, but no hmac_key_file is configured to check it\n", v.Unsigned)
+			}
+			if v.Head != "" {
+				fmt.Printf("  head: %s\n", v.Head)
+			}
+			return nil
+		},
+	}
+	rootCmd.AddCommand(verifyCmd)
+
+	// Parse args starting from "audit"
+	rootCmd.SetArgs(os.Args[2:])
+	return rootCmd.Execute()
+}
+
+// readAuditLog reads every record in the configured audit log. Lines that
+// can't be decoded are reported on stderr, leaving stdout to the output
+// format, and otherwise ignored.
+func readAuditLog() ([]audit.Record, error) {
+	log, err := audit.OpenConfigured()
+	if err != nil {
+		return nil, err
+	}
+	if log == nil {
+		return nil, nil
+	}
+	records, warnings, err := log.Records()
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s (run 'kubectl-guard audit verify' for details)\n", w)
+	}
+	return records, err
+}
+
+// parseTimeRange sets the filter's time bounds from --since and --until.
+func parseTimeRange(f *audit.Filter, since, until string) error {
+	now := time.Now()
+	var err error
+	if since != "" {
+		if f.Since, err = audit.ParseTime(since, now); err != nil {
+			return err
+		}
+	}
+	if until != "" {
+		if f.Until, err = audit.ParseTime(until, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
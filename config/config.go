@@ -2,8 +2,17 @@
 package config
 
 import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
@@ -11,6 +20,262 @@ import (
 // Config represents the kubectl-guard configuration.
 type Config struct {
 	ProtectedContexts []string `yaml:"protected_contexts"`
+	Rules             []Rule   `yaml:"rules,omitempty"`
+	// Mode is the default protection mode; rules may override it.
+	Mode Mode `yaml:"mode,omitempty"`
+	// UnknownCommands is the mode for commands kubectl-guard cannot
+	// classify, such as plugins or new kubectl verbs. Defaults to allow.
+	UnknownCommands Mode `yaml:"unknown_commands,omitempty"`
+	// RiskModes maps risk levels to modes, so low-risk writes can warn while
+	// critical ones need typed confirmation. Levels not listed use Mode.
+	RiskModes map[RiskLevel]Mode `yaml:"risk_modes,omitempty"`
+	// NonInteractive decides commands that need confirmation when there is
+	// no terminal to ask on, such as in CI or cron jobs.
+	NonInteractive NonInteractivePolicy `yaml:"non_interactive,omitempty"`
+	// ApprovalTokenSHA256 is the hex SHA-256 of the token that approves
+	// commands under the token policy.
+	ApprovalTokenSHA256 string `yaml:"approval_token_sha256,omitempty"`
+	// OnError is the mode for state-altering commands that can't be
+	// checked because the config or kubeconfig is unreadable. Defaults to
+	// warn; KUBECTL_GUARD_ON_ERROR overrides it.
+	OnError Mode `yaml:"on_error,omitempty"`
+	// Audit configures the log of decisions on protected contexts.
+	Audit AuditConfig `yaml:"audit,omitempty"`
+	// Supervise runs recorded commands on protected contexts as a child
+	// process, so kubectl's exit status and duration are recorded in the
+	// audit log. Reads and unprotected contexts always hand over to kubectl
+	// directly.
+	Supervise bool `yaml:"supervise,omitempty"`
+	// Redact hides more secrets than the built-in credential flags wherever
+	// arguments are shown or recorded.
+	Redact RedactConfig `yaml:"redact,omitempty"`
+}
+
+// RedactConfig adds to the secrets hidden in displayed and recorded
+// arguments.
+type RedactConfig struct {
+	// Flags are more flags whose values are secret, such as a plugin's
+	// --vault-token. They are assumed to take a value.
+	Flags []string `yaml:"flags,omitempty"`
+	// Patterns are regular expressions; text matching them in any argument
+	// is hidden.
+	Patterns []string `yaml:"patterns,omitempty"`
+}
+
+// AuditConfig configures the audit log, which is on by default.
+type AuditConfig struct {
+	// Disabled turns the audit log off.
+	Disabled bool `yaml:"disabled,omitempty"`
+	// Path is the JSONL file records are appended to. A leading ~/ is the
+	// home directory. Defaults to ~/.kubectl-guard/audit.jsonl.
+	Path string `yaml:"path,omitempty"`
+	// MaxSizeMB rotates the log once it would grow past this size.
+	// Defaults to 10.
+	MaxSizeMB int `yaml:"max_size_mb,omitempty"`
+	// MaxFiles is how many rotated logs to keep. Defaults to 5.
+	MaxFiles int `yaml:"max_files,omitempty"`
+	// HMACKeyFile names a file holding a secret key. Records are then
+	// signed with an HMAC-SHA256 of the key, so the hash chain can't be
+	// recomputed by someone who edits the log without it. A leading ~/ is
+	// the home directory.
+	HMACKeyFile string `yaml:"hmac_key_file,omitempty"`
+	// Sinks forward each record to a central log pipeline as well.
+	Sinks []SinkConfig `yaml:"sinks,omitempty"`
+}
+
+// SinkType is where a sink forwards audit records.
+type SinkType string
+
+const (
+	// SinkSyslog sends RFC 5424 messages over UDP, TCP or a unix socket.
+	SinkSyslog SinkType = "syslog"
+	// SinkWebhook POSTs each record as JSON.
+	SinkWebhook SinkType = "webhook"
+	// SinkOTLP POSTs each record as an OTLP/HTTP log, JSON-encoded.
+	SinkOTLP SinkType = "otlp"
+)
+
+// SinkConfig configures one audit sink.
+type SinkConfig struct {
+	Type SinkType `yaml:"type"`
+	// Network is udp, tcp or unix, and Address is host:port or the socket
+	// path. Syslog only.
+	Network string `yaml:"network,omitempty"`
+	Address string `yaml:"address,omitempty"`
+	// URL is the endpoint of a webhook or OTLP collector, e.g.
+	// http://localhost:4318/v1/logs.
+	URL string `yaml:"url,omitempty"`
+	// Headers are sent with each request. $VARS in values are expanded from
+	// the environment, so tokens needn't live in this file.
+	Headers map[string]string `yaml:"headers,omitempty"`
+	// TimeoutSeconds bounds how long forwarding a record, retries
+	// included, may hold up kubectl. Defaults to 2.
+	TimeoutSeconds int `yaml:"timeout_seconds,omitempty"`
+	// Spool is the directory HTTP records wait in until they are
+	// delivered, with the next record if not this one. Defaults to a
+	// directory beside the audit log.
+	Spool string `yaml:"spool,omitempty"`
+}
+
+// String describes the sink by its type and destination.
+func (s SinkConfig) String() string {
+	if s.Type == SinkSyslog {
+		return fmt.Sprintf("%s %s://%s", s.Type, s.Network, s.Address)
+	}
+	return fmt.Sprintf("%s %s", s.Type, s.URL)
+}
+
+// Validate checks that the sink has what its type needs.
+func (s SinkConfig) Validate() error {
+	if s.TimeoutSeconds < 0 {
+		return fmt.Errorf("timeout_seconds must not be negative")
+	}
+	switch s.Type {
+	case SinkSyslog:
+		if s.Network != "udp" && s.Network != "tcp" && s.Network != "unix" {
+			return fmt.Errorf("invalid network %q (want udp, tcp or unix)", s.Network)
+		}
+		if s.Address == "" {
+			return fmt.Errorf("syslog needs an address")
+		}
+	case SinkWebhook, SinkOTLP:
+		u, err := url.Parse(s.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid url %q (want an http or https URL)", s.URL)
+		}
+	default:
+		return fmt.Errorf("invalid type %q (want syslog, webhook or otlp)", s.Type)
+	}
+	return nil
+}
+
+// EnvOnError overrides on_error. Unlike the config file, it still applies
+// when the config file itself is broken.
+const EnvOnError = "KUBECTL_GUARD_ON_ERROR"
+
+// OnErrorModes are the modes on_error accepts. Typed confirmation needs a
+// context name, which may be what couldn't be resolved.
+var OnErrorModes = []Mode{ModeAllow, ModeWarn, ModeConfirm, ModeBlock}
+
+// NonInteractivePolicy controls commands that need confirmation when no
+// terminal is available. Unset, they are refused as having no terminal.
+type NonInteractivePolicy string
+
+const (
+	// NonInteractiveDeny refuses the command as a policy decision.
+	NonInteractiveDeny NonInteractivePolicy = "deny"
+	// NonInteractiveAllow runs the command and records the approval.
+	NonInteractiveAllow NonInteractivePolicy = "allow"
+	// NonInteractiveToken runs the command only if the caller presents the
+	// approval token.
+	NonInteractiveToken NonInteractivePolicy = "token"
+)
+
+// HashToken returns the hex SHA-256 of an approval token, as stored in
+// approval_token_sha256.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// TokenMatches reports whether token hashes to the configured approval
+// token, comparing in constant time.
+func (c *Config) TokenMatches(token string) bool {
+	if token == "" || c.ApprovalTokenSHA256 == "" {
+		return false
+	}
+	want := strings.ToLower(strings.TrimSpace(c.ApprovalTokenSHA256))
+	return subtle.ConstantTimeCompare([]byte(HashToken(token)), []byte(want)) == 1
+}
+
+// Mode controls how state-altering commands on protected contexts are handled.
+type Mode string
+
+const (
+	// ModeAllow runs the command without prompting.
+	ModeAllow Mode = "allow"
+	// ModeWarn prints a warning banner and runs the command.
+	ModeWarn Mode = "warn"
+	// ModeConfirm asks for a y/N confirmation.
+	ModeConfirm Mode = "confirm"
+	// ModeTyped requires typing the context name to confirm.
+	ModeTyped Mode = "typed"
+	// ModeBlock refuses to run the command.
+	ModeBlock Mode = "block"
+)
+
+// Modes lists the protection modes from least to most strict.
+var Modes = []Mode{ModeAllow, ModeWarn, ModeConfirm, ModeTyped, ModeBlock}
+
+// ParseMode validates a mode name.
+func ParseMode(s string) (Mode, error) {
+	for _, m := range Modes {
+		if string(m) == s {
+			return m, nil
+		}
+	}
+	return "", fmt.Errorf("invalid mode %q (want allow, warn, confirm, typed or block)", s)
+}
+
+// RiskLevel grades how much damage a state-altering command can do.
+type RiskLevel string
+
+const (
+	// RiskLow commands are easily undone, e.g. deleting a single pod.
+	RiskLow RiskLevel = "low"
+	// RiskMedium commands change or remove workloads and their config.
+	RiskMedium RiskLevel = "medium"
+	// RiskHigh commands affect nodes, storage, RBAC or many resources.
+	RiskHigh RiskLevel = "high"
+	// RiskCritical commands can take down whole namespaces or APIs.
+	RiskCritical RiskLevel = "critical"
+)
+
+// RiskLevels lists the risk levels from lowest to highest.
+var RiskLevels = []RiskLevel{RiskLow, RiskMedium, RiskHigh, RiskCritical}
+
+// ParseRiskLevel validates a risk level name.
+func ParseRiskLevel(s string) (RiskLevel, error) {
+	for _, l := range RiskLevels {
+		if string(l) == s {
+			return l, nil
+		}
+	}
+	return "", fmt.Errorf("invalid risk level %q (want low, medium, high or critical)", s)
+}
+
+// Rule protects clusters by properties other than just the context name, so
+// protection survives renamed contexts or several contexts pointing at the
+// same API server. Every field that is set must match. When Namespaces is
+// set, only commands affecting a matching namespace are protected.
+type Rule struct {
+	Context       string   `yaml:"context,omitempty"`
+	Cluster       string   `yaml:"cluster,omitempty"`
+	Server        string   `yaml:"server,omitempty"`
+	CAFingerprint string   `yaml:"ca_fingerprint,omitempty"`
+	Namespaces    []string `yaml:"namespaces,omitempty"`
+	Mode          Mode     `yaml:"mode,omitempty"`
+	// UnknownCommands overrides Config.UnknownCommands for matching targets.
+	UnknownCommands Mode `yaml:"unknown_commands,omitempty"`
+	// RiskModes overrides the mode for specific risk levels.
+	RiskModes map[RiskLevel]Mode `yaml:"risk_modes,omitempty"`
+}
+
+// Target describes the cluster and namespace a command will run against.
+type Target struct {
+	Context       string
+	Cluster       string
+	Server        string
+	CAFingerprint string
+	Namespace     string
+	AllNamespaces bool
+	// Namespaces are other namespaces the command affects, such as
+	// Namespace objects it deletes or the namespaces of its manifests.
+	Namespaces []string
+	// UnknownNamespaces is set when the command may affect namespaces that
+	// could not be determined, such as those of manifests piped on stdin.
+	// It matches every namespace rule, like AllNamespaces.
+	UnknownNamespaces bool
 }
 
 const configFileName = ".kubectl-guard.yaml"
@@ -54,12 +319,155 @@ func Load() (*Config, error) {
 
 	var cfg Config
 	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
 	}
 
 	return &cfg, nil
 }
 
+// Validate checks that every configured mode and risk level is known.
+func (c *Config) Validate() error {
+	if err := validateModes(c.Mode, c.UnknownCommands); err != nil {
+		return err
+	}
+	if err := validateRiskModes(c.RiskModes); err != nil {
+		return err
+	}
+	if c.OnError != "" && !slices.Contains(OnErrorModes, c.OnError) {
+		return fmt.Errorf("invalid on_error %q (want allow, warn, confirm or block)", c.OnError)
+	}
+	if c.Audit.MaxSizeMB < 0 || c.Audit.MaxFiles < 0 {
+		return fmt.Errorf("audit: max_size_mb and max_files must not be negative")
+	}
+	for _, flag := range c.Redact.Flags {
+		if !strings.HasPrefix(flag, "--") || len(flag) < 3 || strings.Contains(flag, "=") {
+			return fmt.Errorf("redact: invalid flag %q (want e.g. --vault-token)", flag)
+		}
+	}
+	for _, pattern := range c.Redact.Patterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("redact: invalid pattern %q: %w", pattern, err)
+		}
+	}
+	for i, sink := range c.Audit.Sinks {
+		if err := sink.Validate(); err != nil {
+			return fmt.Errorf("audit: sink %d: %w", i+1, err)
+		}
+	}
+	switch c.NonInteractive {
+	case "", NonInteractiveDeny, NonInteractiveAllow:
+	case NonInteractiveToken:
+		if c.ApprovalTokenSHA256 == "" {
+			return fmt.Errorf("non_interactive: token requires approval_token_sha256")
+		}
+	default:
+		return fmt.Errorf("invalid non_interactive %q (want deny, allow or token)", c.NonInteractive)
+	}
+	for _, r := range c.Rules {
+		if err := validateModes(r.Mode, r.UnknownCommands); err != nil {
+			return fmt.Errorf("rule %s: %w", r, err)
+		}
+		if err := validateRiskModes(r.RiskModes); err != nil {
+			return fmt.Errorf("rule %s: %w", r, err)
+		}
+	}
+	return nil
+}
+
+// validateRiskModes checks the levels and modes of a risk_modes map.
+func validateRiskModes(riskModes map[RiskLevel]Mode) error {
+	for level, mode := range riskModes {
+		if _, err := ParseRiskLevel(string(level)); err != nil {
+			return fmt.Errorf("risk_modes: %w", err)
+		}
+		if err := validateModes(mode); err != nil {
+			return fmt.Errorf("risk_modes %s: %w", level, err)
+		}
+	}
+	return nil
+}
+
+// validateModes checks that each mode is either unset or known.
+func validateModes(modes ...Mode) error {
+	for _, m := range modes {
+		if m == "" {
+			continue
+		}
+		if _, err := ParseMode(string(m)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OnErrorMode returns the mode for commands that can't be checked:
+// KUBECTL_GUARD_ON_ERROR, then on_error from cfg, then warn. When cfg is
+// nil because the config failed to load, on_error is still read from the
+// file if it parses as YAML at all. Invalid values are skipped.
+func OnErrorMode(cfg *Config) Mode {
+	if m := Mode(os.Getenv(EnvOnError)); slices.Contains(OnErrorModes, m) {
+		return m
+	}
+	if cfg == nil {
+		cfg = readOnError()
+	}
+	if slices.Contains(OnErrorModes, cfg.OnError) {
+		return cfg.OnError
+	}
+	return ModeWarn
+}
+
+// readOnError reads only on_error from a config file that may not load.
+func readOnError() *Config {
+	var cfg Config
+	path, err := Path()
+	if err != nil {
+		return &cfg
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return &cfg
+	}
+	var partial struct {
+		OnError Mode `yaml:"on_error"`
+	}
+	if yaml.Unmarshal(data, &partial) == nil {
+		cfg.OnError = partial.OnError
+	}
+	return &cfg
+}
+
+// ModeFor returns the mode that applies to a matched rule: the rule's own
+// mode, then the configured default, then confirm.
+func (c *Config) ModeFor(rule Rule) Mode {
+	if rule.Mode != "" {
+		return rule.Mode
+	}
+	if c.Mode != "" {
+		return c.Mode
+	}
+	return ModeConfirm
+}
+
+// ModeForRisk returns the mode for a command of the given risk under a
+// matched rule. The most specific setting wins: the rule's risk_modes, the
+// rule's mode, the global risk_modes, the global mode, then confirm.
+func (c *Config) ModeForRisk(rule Rule, risk RiskLevel) Mode {
+	if m, ok := rule.RiskModes[risk]; ok && m != "" {
+		return m
+	}
+	if rule.Mode != "" {
+		return rule.Mode
+	}
+	if m, ok := c.RiskModes[risk]; ok && m != "" {
+		return m
+	}
+	return c.ModeFor(rule)
+}
+
 // Save writes the config to disk.
 func Save(cfg *Config) error {
 	path, err := Path()
@@ -76,16 +484,228 @@ func Save(cfg *Config) error {
 	return os.WriteFile(path, []byte(header+string(data)), 0644)
 }
 
-// IsContextProtected checks if a context matches any protected pattern.
-func (c *Config) IsContextProtected(context string) bool {
+// Match returns every protection entry matching the target, in the order
+// of Entries. Plain protected_contexts patterns are reported as rules with
+// only Context set. When several match, the strictest mode among them
+// applies; see Strictest.
+func (c *Config) Match(t Target) []Rule {
+	var rules []Rule
+	for _, rule := range c.Entries() {
+		if rule.Matches(t) {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// LimitedToOtherNamespaces reports whether an entry matches the target's
+// cluster but none of the namespaces it is known to affect. Only then can
+// learning more of the namespaces a command affects change what matches.
+func (c *Config) LimitedToOtherNamespaces(t Target) bool {
+	for _, rule := range c.Entries() {
+		if !rule.IsEmpty() && rule.MatchesCluster(t) && !rule.MatchesNamespace(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Strictest returns the strictest of the modes modeFor gives the rules, and
+// the first rule that gets it, so overlapping entries can only tighten
+// protection. rules must not be empty.
+func Strictest(rules []Rule, modeFor func(Rule) Mode) (Rule, Mode) {
+	rule, mode := rules[0], modeFor(rules[0])
+	for _, r := range rules[1:] {
+		if m := modeFor(r); slices.Index(Modes, m) > slices.Index(Modes, mode) {
+			rule, mode = r, m
+		}
+	}
+	return rule, mode
+}
+
+// Entries returns every protection entry, protected_contexts patterns first.
+func (c *Config) Entries() []Rule {
+	entries := make([]Rule, 0, len(c.ProtectedContexts)+len(c.Rules))
 	for _, pattern := range c.ProtectedContexts {
-		if matched, _ := filepath.Match(pattern, context); matched {
+		entries = append(entries, Rule{Context: pattern})
+	}
+	return append(entries, c.Rules...)
+}
+
+// Matches reports whether every field set on the rule matches the target,
+// including its namespace. A rule with no fields set matches nothing.
+func (r Rule) Matches(t Target) bool {
+	if r.IsEmpty() {
+		return false
+	}
+	return r.MatchesCluster(t) && r.MatchesNamespace(t)
+}
+
+// MatchesNamespace reports whether any namespace the target affects is
+// covered by the rule. Rules without namespaces cover every namespace, and
+// an all-namespaces command, or one whose namespaces are unknown, touches
+// every protected namespace.
+func (r Rule) MatchesNamespace(t Target) bool {
+	if len(r.Namespaces) == 0 || t.AllNamespaces || t.UnknownNamespaces {
+		return true
+	}
+	for _, ns := range append([]string{t.Namespace}, t.Namespaces...) {
+		for _, pattern := range r.Namespaces {
+			if globMatch(pattern, ns) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// MatchesCluster reports whether the rule's context, cluster, server and CA
+// fields match the target, ignoring namespaces.
+func (r Rule) MatchesCluster(t Target) bool {
+	if r.Context != "" && !globMatch(r.Context, t.Context) {
+		return false
+	}
+	if r.Cluster != "" && !globMatch(r.Cluster, t.Cluster) {
+		return false
+	}
+	if r.Server != "" && !serverMatch(r.Server, t.Server) {
+		return false
+	}
+	if r.CAFingerprint != "" && NormalizeFingerprint(r.CAFingerprint) != NormalizeFingerprint(t.CAFingerprint) {
+		return false
+	}
+	return true
+}
+
+// IsEmpty reports whether the rule has no match fields set.
+func (r Rule) IsEmpty() bool {
+	return r.Context == "" && r.Cluster == "" && r.Server == "" && r.CAFingerprint == "" && len(r.Namespaces) == 0
+}
+
+// Equal reports whether two rules have identical fields.
+func (r Rule) Equal(other Rule) bool {
+	return r.Context == other.Context &&
+		r.Cluster == other.Cluster &&
+		r.Server == other.Server &&
+		r.CAFingerprint == other.CAFingerprint &&
+		slices.Equal(r.Namespaces, other.Namespaces) &&
+		r.Mode == other.Mode &&
+		r.UnknownCommands == other.UnknownCommands &&
+		maps.Equal(r.RiskModes, other.RiskModes)
+}
+
+// sameMatch reports whether two rules match the same targets, ignoring modes.
+func (r Rule) sameMatch(other Rule) bool {
+	other.Mode = r.Mode
+	other.UnknownCommands = r.UnknownCommands
+	other.RiskModes = r.RiskModes
+	return r.Equal(other)
+}
+
+// String returns a compact description of the rule for listings.
+func (r Rule) String() string {
+	var parts []string
+	if r.Context != "" {
+		parts = append(parts, "context="+r.Context)
+	}
+	if r.Cluster != "" {
+		parts = append(parts, "cluster="+r.Cluster)
+	}
+	if r.Server != "" {
+		parts = append(parts, "server="+r.Server)
+	}
+	if r.CAFingerprint != "" {
+		parts = append(parts, "ca_fingerprint="+r.CAFingerprint)
+	}
+	if len(r.Namespaces) > 0 {
+		parts = append(parts, "namespaces="+strings.Join(r.Namespaces, ","))
+	}
+	if r.Mode != "" {
+		parts = append(parts, "mode="+string(r.Mode))
+	}
+	if r.UnknownCommands != "" {
+		parts = append(parts, "unknown_commands="+string(r.UnknownCommands))
+	}
+	for _, level := range RiskLevels {
+		if m, ok := r.RiskModes[level]; ok {
+			parts = append(parts, "risk_modes."+string(level)+"="+string(m))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// NormalizeFingerprint lowercases a SHA-256 fingerprint and strips an
+// optional "sha256:" prefix and colon separators, so fingerprints copied from
+// openssl or browsers compare equal.
+func NormalizeFingerprint(fp string) string {
+	fp = strings.ToLower(strings.TrimSpace(fp))
+	fp = strings.TrimPrefix(fp, "sha256:")
+	return strings.ReplaceAll(fp, ":", "")
+}
+
+func globMatch(pattern, value string) bool {
+	matched, _ := filepath.Match(pattern, value)
+	return matched
+}
+
+// serverMatch matches an API server URL. Patterns containing a scheme are
+// matched against the whole URL; otherwise they match the host, with or
+// without its port.
+func serverMatch(pattern, server string) bool {
+	if server == "" {
+		return false
+	}
+	server = strings.TrimSuffix(server, "/")
+	if strings.Contains(pattern, "://") {
+		return globMatch(strings.TrimSuffix(pattern, "/"), server)
+	}
+
+	u, err := url.Parse(server)
+	if err != nil || u.Host == "" {
+		return globMatch(pattern, server)
+	}
+	return globMatch(pattern, u.Host) || globMatch(pattern, u.Hostname())
+}
+
+// AddRule adds a rule, or updates the modes of an existing rule with the same
+// match fields. Returns false if nothing changed.
+func (c *Config) AddRule(rule Rule) bool {
+	for i, r := range c.Rules {
+		if r.sameMatch(rule) {
+			if r.Equal(rule) {
+				return false
+			}
+			c.Rules[i] = rule
+			return true
+		}
+	}
+	c.Rules = append(c.Rules, rule)
+	return true
+}
+
+// RemoveRule removes the rule with the same match fields, whatever its mode.
+func (c *Config) RemoveRule(rule Rule) bool {
+	for i, r := range c.Rules {
+		if r.sameMatch(rule) {
+			c.Rules = append(c.Rules[:i], c.Rules[i+1:]...)
 			return true
 		}
 	}
 	return false
 }
 
+// UnknownModeFor returns the mode for unclassified commands under a matched
+// rule: the rule's own setting, then the configured default, then allow.
+func (c *Config) UnknownModeFor(rule Rule) Mode {
+	if rule.UnknownCommands != "" {
+		return rule.UnknownCommands
+	}
+	if c.UnknownCommands != "" {
+		return c.UnknownCommands
+	}
+	return ModeAllow
+}
+
 // AddContext adds a context to the protected list if not already present.
 func (c *Config) AddContext(context string) bool {
 	for _, ctx := range c.ProtectedContexts {
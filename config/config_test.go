@@ -3,10 +3,11 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
-func TestIsContextProtected(t *testing.T) {
+func TestMatchProtectedContexts(t *testing.T) {
 	tests := []struct {
 		name      string
 		patterns  []string
@@ -72,9 +73,9 @@ func TestIsContextProtected(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			cfg := &Config{ProtectedContexts: tt.patterns}
-			got := cfg.IsContextProtected(tt.context)
+			got := len(cfg.Match(Target{Context: tt.context})) > 0
 			if got != tt.protected {
-				t.Errorf("IsContextProtected(%q) = %v, want %v", tt.context, got, tt.protected)
+				t.Errorf("Match(%q) matched = %v, want %v", tt.context, got, tt.protected)
 			}
 		})
 	}
@@ -191,3 +192,424 @@ func TestPath(t *testing.T) {
 		t.Errorf("Expected filename %q, got %q", configFileName, filepath.Base(path))
 	}
 }
+
+func TestRuleMatches(t *testing.T) {
+	target := Target{
+		Context:       "renamed-prod",
+		Cluster:       "prod-cluster",
+		Server:        "https://api.prod.example.com:6443",
+		CAFingerprint: "ab12cd34",
+	}
+
+	tests := []struct {
+		name  string
+		rule  Rule
+		match bool
+	}{
+		{"context glob", Rule{Context: "*-prod"}, true},
+		{"context mismatch", Rule{Context: "prod-*"}, false},
+		{"cluster name", Rule{Cluster: "prod-*"}, true},
+		{"cluster mismatch", Rule{Cluster: "staging"}, false},
+		{"server host glob", Rule{Server: "*.prod.example.com"}, true},
+		{"server host and port", Rule{Server: "api.prod.example.com:6443"}, true},
+		{"server full url", Rule{Server: "https://api.prod.example.com:6443/"}, true},
+		{"server url glob", Rule{Server: "https://*.prod.example.com:*"}, true},
+		{"server mismatch", Rule{Server: "*.staging.example.com"}, false},
+		{"fingerprint", Rule{CAFingerprint: "AB:12:CD:34"}, true},
+		{"fingerprint with prefix", Rule{CAFingerprint: "sha256:ab12cd34"}, true},
+		{"fingerprint mismatch", Rule{CAFingerprint: "ffff"}, false},
+		{"all fields must match", Rule{Cluster: "prod-cluster", Server: "*.staging.example.com"}, false},
+		{"empty rule", Rule{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule.Matches(target); got != tt.match {
+				t.Errorf("%+v.Matches() = %v, want %v", tt.rule, got, tt.match)
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	cfg := &Config{
+		ProtectedContexts: []string{"prod-*"},
+		Rules:             []Rule{{Server: "*.prod.example.com"}},
+	}
+
+	rules := cfg.Match(Target{Context: "prod-eu"})
+	if len(rules) != 1 || rules[0].Context != "prod-*" {
+		t.Errorf("Match(prod-eu) = %+v, want context pattern", rules)
+	}
+
+	rules = cfg.Match(Target{Context: "minikube", Server: "https://api.prod.example.com"})
+	if len(rules) != 1 || rules[0].Server != "*.prod.example.com" {
+		t.Errorf("Match(minikube on prod server) = %+v, want server rule", rules)
+	}
+
+	rules = cfg.Match(Target{Context: "prod-eu", Server: "https://api.prod.example.com"})
+	if len(rules) != 2 || rules[0].Context != "prod-*" || rules[1].Server != "*.prod.example.com" {
+		t.Errorf("Match(prod-eu on prod server) = %+v, want both entries", rules)
+	}
+
+	if rules := cfg.Match(Target{Context: "minikube", Server: "https://127.0.0.1:8443"}); len(rules) != 0 {
+		t.Errorf("Match(minikube) = %+v, want none", rules)
+	}
+}
+
+func TestStrictest(t *testing.T) {
+	cfg := &Config{Mode: ModeConfirm}
+	rules := []Rule{
+		{Context: "prod"},
+		{Namespaces: []string{"kube-system"}, Mode: ModeBlock},
+		{Server: "*.prod.example.com", Mode: ModeWarn, RiskModes: map[RiskLevel]Mode{RiskLow: ModeTyped}},
+	}
+
+	rule, mode := Strictest(rules, cfg.ModeFor)
+	if mode != ModeBlock || rule.Mode != ModeBlock {
+		t.Errorf("Strictest(ModeFor) = (%s, %s), want the block rule", rule, mode)
+	}
+
+	// A permissive rule can't loosen a stricter one, whatever the order.
+	rule, mode = Strictest([]Rule{rules[2], rules[0]}, func(r Rule) Mode { return cfg.ModeForRisk(r, RiskMedium) })
+	if mode != ModeConfirm || rule.Context != "prod" {
+		t.Errorf("Strictest(medium) = (%s, %s), want confirm from context=prod", rule, mode)
+	}
+	rule, mode = Strictest([]Rule{rules[0], rules[2]}, func(r Rule) Mode { return cfg.ModeForRisk(r, RiskLow) })
+	if mode != ModeTyped || rule.Server == "" {
+		t.Errorf("Strictest(low) = (%s, %s), want typed from the server rule", rule, mode)
+	}
+
+	// Ties go to the first rule.
+	if rule, _ := Strictest([]Rule{{Context: "a"}, {Context: "b"}}, cfg.ModeFor); rule.Context != "a" {
+		t.Errorf("Strictest(tie) = %s, want context=a", rule)
+	}
+}
+
+func TestAddRemoveRule(t *testing.T) {
+	cfg := &Config{}
+	rule := Rule{Cluster: "prod-cluster"}
+
+	if !cfg.AddRule(rule) {
+		t.Error("AddRule returned false for new rule")
+	}
+	if cfg.AddRule(rule) {
+		t.Error("AddRule returned true for duplicate rule")
+	}
+	if !cfg.RemoveRule(rule) {
+		t.Error("RemoveRule returned false for existing rule")
+	}
+	if len(cfg.Rules) != 0 {
+		t.Errorf("Expected no rules, got %d", len(cfg.Rules))
+	}
+}
+
+func TestRuleNamespaces(t *testing.T) {
+	rule := Rule{Context: "shared-cluster", Namespaces: []string{"payments-*", "kube-system"}}
+
+	tests := []struct {
+		name   string
+		target Target
+		match  bool
+	}{
+		{"protected namespace", Target{Context: "shared-cluster", Namespace: "kube-system"}, true},
+		{"protected namespace glob", Target{Context: "shared-cluster", Namespace: "payments-api"}, true},
+		{"sandbox namespace", Target{Context: "shared-cluster", Namespace: "team-sandbox"}, false},
+		{"all namespaces", Target{Context: "shared-cluster", AllNamespaces: true}, true},
+		{"affected namespace", Target{Context: "shared-cluster", Namespace: "team-sandbox", Namespaces: []string{"payments-prod"}}, true},
+		{"affected sandbox namespace", Target{Context: "shared-cluster", Namespace: "team-sandbox", Namespaces: []string{"team-dev"}}, false},
+		{"unknown namespaces", Target{Context: "shared-cluster", Namespace: "team-sandbox", UnknownNamespaces: true}, true},
+		{"other context", Target{Context: "minikube", Namespace: "kube-system"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rule.Matches(tt.target); got != tt.match {
+				t.Errorf("Matches(%+v) = %v, want %v", tt.target, got, tt.match)
+			}
+		})
+	}
+
+	// A namespace-only rule applies on every cluster.
+	nsOnly := Rule{Namespaces: []string{"kube-system"}}
+	if !nsOnly.Matches(Target{Context: "anything", Namespace: "kube-system"}) {
+		t.Error("namespace-only rule should match kube-system on any context")
+	}
+	if !rule.MatchesCluster(Target{Context: "shared-cluster", Namespace: "team-sandbox"}) {
+		t.Error("MatchesCluster should ignore namespaces")
+	}
+}
+
+func TestLimitedToOtherNamespaces(t *testing.T) {
+	cfg := &Config{
+		ProtectedContexts: []string{"prod"},
+		Rules:             []Rule{{Context: "shared-cluster", Namespaces: []string{"payments-*"}}},
+	}
+
+	tests := []struct {
+		name   string
+		target Target
+		want   bool
+	}{
+		{"other namespace", Target{Context: "shared-cluster", Namespace: "team-sandbox"}, true},
+		{"protected namespace", Target{Context: "shared-cluster", Namespace: "payments-api"}, false},
+		{"unknown namespaces", Target{Context: "shared-cluster", Namespace: "team-sandbox", UnknownNamespaces: true}, false},
+		{"whole context protected", Target{Context: "prod", Namespace: "team-sandbox"}, false},
+		{"other cluster", Target{Context: "minikube", Namespace: "team-sandbox"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cfg.LimitedToOtherNamespaces(tt.target); got != tt.want {
+				t.Errorf("LimitedToOtherNamespaces(%+v) = %v, want %v", tt.target, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestModeFor(t *testing.T) {
+	cfg := &Config{}
+	if got := cfg.ModeFor(Rule{Context: "prod"}); got != ModeConfirm {
+		t.Errorf("ModeFor() with no modes = %q, want confirm", got)
+	}
+
+	cfg.Mode = ModeWarn
+	if got := cfg.ModeFor(Rule{Context: "prod"}); got != ModeWarn {
+		t.Errorf("ModeFor() with default = %q, want warn", got)
+	}
+	if got := cfg.ModeFor(Rule{Context: "prod", Mode: ModeBlock}); got != ModeBlock {
+		t.Errorf("ModeFor() with rule mode = %q, want block", got)
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	for _, m := range Modes {
+		if got, err := ParseMode(string(m)); err != nil || got != m {
+			t.Errorf("ParseMode(%q) = (%q, %v)", m, got, err)
+		}
+	}
+	if _, err := ParseMode("prompt"); err == nil {
+		t.Error("ParseMode(prompt) error = nil, want error")
+	}
+}
+
+func TestLoadRejectsInvalidMode(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+
+	data := "protected_contexts: [prod]\nrules:\n  - context: staging\n    mode: sometimes\n"
+	if err := os.WriteFile(filepath.Join(tmpDir, configFileName), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(); err == nil {
+		t.Error("Load() error = nil, want invalid mode error")
+	}
+}
+
+func TestAddRuleUpdatesMode(t *testing.T) {
+	cfg := &Config{}
+	cfg.AddRule(Rule{Context: "prod-*"})
+
+	if !cfg.AddRule(Rule{Context: "prod-*", Mode: ModeBlock}) {
+		t.Error("AddRule returned false when changing mode")
+	}
+	if len(cfg.Rules) != 1 || cfg.Rules[0].Mode != ModeBlock {
+		t.Errorf("Rules = %+v, want one block rule", cfg.Rules)
+	}
+	if !cfg.RemoveRule(Rule{Context: "prod-*"}) {
+		t.Error("RemoveRule should ignore mode")
+	}
+}
+
+func TestUnknownModeFor(t *testing.T) {
+	cfg := &Config{}
+	if got := cfg.UnknownModeFor(Rule{Context: "prod"}); got != ModeAllow {
+		t.Errorf("UnknownModeFor() default = %q, want allow", got)
+	}
+
+	cfg.UnknownCommands = ModeConfirm
+	if got := cfg.UnknownModeFor(Rule{Context: "prod"}); got != ModeConfirm {
+		t.Errorf("UnknownModeFor() with default = %q, want confirm", got)
+	}
+	if got := cfg.UnknownModeFor(Rule{Context: "prod", UnknownCommands: ModeBlock}); got != ModeBlock {
+		t.Errorf("UnknownModeFor() with rule = %q, want block", got)
+	}
+}
+
+func TestModeForRisk(t *testing.T) {
+	cfg := &Config{
+		Mode:      ModeConfirm,
+		RiskModes: map[RiskLevel]Mode{RiskLow: ModeWarn, RiskCritical: ModeTyped},
+	}
+	plain := Rule{Context: "prod"}
+
+	tests := []struct {
+		name string
+		rule Rule
+		risk RiskLevel
+		want Mode
+	}{
+		{"global risk mode", plain, RiskLow, ModeWarn},
+		{"falls back to mode", plain, RiskMedium, ModeConfirm},
+		{"global critical", plain, RiskCritical, ModeTyped},
+		{"rule mode beats global risk modes", Rule{Context: "prod", Mode: ModeBlock}, RiskLow, ModeBlock},
+		{"rule risk mode", Rule{Context: "prod", Mode: ModeWarn, RiskModes: map[RiskLevel]Mode{RiskHigh: ModeBlock}}, RiskHigh, ModeBlock},
+		{"rule mode for other levels", Rule{Context: "prod", Mode: ModeWarn, RiskModes: map[RiskLevel]Mode{RiskHigh: ModeBlock}}, RiskMedium, ModeWarn},
+	}
+	for _, tt := range tests {
+		if got := cfg.ModeForRisk(tt.rule, tt.risk); got != tt.want {
+			t.Errorf("%s: ModeForRisk(%v, %q) = %q, want %q", tt.name, tt.rule, tt.risk, got, tt.want)
+		}
+	}
+
+	if got := (&Config{}).ModeForRisk(plain, RiskCritical); got != ModeConfirm {
+		t.Errorf("ModeForRisk() with no modes = %q, want confirm", got)
+	}
+}
+
+func TestLoadRejectsInvalidRiskModes(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+
+	for _, data := range []string{
+		"protected_contexts: [prod]\nrisk_modes:\n  severe: typed\n",
+		"protected_contexts: [prod]\nrules:\n  - context: staging\n    risk_modes:\n      low: maybe\n",
+	} {
+		if err := os.WriteFile(filepath.Join(tmpDir, configFileName), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(); err == nil {
+			t.Errorf("Load(%q) error = nil, want invalid risk_modes error", data)
+		}
+	}
+}
+
+func TestLoadRejectsInvalidNonInteractive(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+
+	for _, data := range []string{
+		"protected_contexts: [prod]\nnon_interactive: sometimes\n",
+		"protected_contexts: [prod]\nnon_interactive: token\n",
+	} {
+		if err := os.WriteFile(filepath.Join(tmpDir, configFileName), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(); err == nil {
+			t.Errorf("Load(%q) error = nil, want invalid non_interactive error", data)
+		}
+	}
+}
+
+func TestTokenMatches(t *testing.T) {
+	cfg := &Config{ApprovalTokenSHA256: strings.ToUpper(HashToken("s3cret"))}
+
+	if !cfg.TokenMatches("s3cret") {
+		t.Error("TokenMatches(correct token) = false, want true")
+	}
+	for _, token := range []string{"", "s3cret ", "other"} {
+		if cfg.TokenMatches(token) {
+			t.Errorf("TokenMatches(%q) = true, want false", token)
+		}
+	}
+	if (&Config{}).TokenMatches("s3cret") {
+		t.Error("TokenMatches without a configured hash = true, want false")
+	}
+}
+
+func TestOnErrorMode(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	t.Setenv(EnvOnError, "")
+
+	if got := OnErrorMode(&Config{}); got != ModeWarn {
+		t.Errorf("OnErrorMode(default) = %s, want warn", got)
+	}
+	if got := OnErrorMode(&Config{OnError: ModeBlock}); got != ModeBlock {
+		t.Errorf("OnErrorMode(on_error: block) = %s, want block", got)
+	}
+
+	// A config that fails validation still yields its on_error.
+	data := "protected_contexts: [prod]\nmode: sometimes\non_error: confirm\n"
+	if err := os.WriteFile(filepath.Join(tmpDir, configFileName), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(); err == nil {
+		t.Fatal("Load() error = nil, want invalid mode error")
+	}
+	if got := OnErrorMode(nil); got != ModeConfirm {
+		t.Errorf("OnErrorMode(nil) = %s, want confirm", got)
+	}
+
+	t.Setenv(EnvOnError, "block")
+	if got := OnErrorMode(&Config{OnError: ModeAllow}); got != ModeBlock {
+		t.Errorf("OnErrorMode with %s=block = %s, want block", EnvOnError, got)
+	}
+}
+
+func TestLoadRejectsInvalidOnError(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+
+	data := "protected_contexts: [prod]\non_error: typed\n"
+	if err := os.WriteFile(filepath.Join(tmpDir, configFileName), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(); err == nil {
+		t.Error("Load() error = nil, want invalid on_error error")
+	}
+}
+
+func TestSinkConfigValidate(t *testing.T) {
+	tests := []struct {
+		sink    SinkConfig
+		wantErr bool
+	}{
+		{SinkConfig{Type: SinkSyslog, Network: "udp", Address: "localhost:514"}, false},
+		{SinkConfig{Type: SinkSyslog, Network: "unix", Address: "/dev/log"}, false},
+		{SinkConfig{Type: SinkWebhook, URL: "https://audit.example.com/hook", TimeoutSeconds: 5}, false},
+		{SinkConfig{Type: SinkOTLP, URL: "http://localhost:4318/v1/logs"}, false},
+		{SinkConfig{Type: SinkSyslog, Network: "sctp", Address: "localhost:514"}, true},
+		{SinkConfig{Type: SinkSyslog, Network: "tcp"}, true},
+		{SinkConfig{Type: SinkWebhook, URL: "audit.example.com/hook"}, true},
+		{SinkConfig{Type: SinkOTLP, URL: "ftp://collector/v1/logs"}, true},
+		{SinkConfig{Type: SinkWebhook, URL: "https://audit.example.com", TimeoutSeconds: -1}, true},
+		{SinkConfig{Type: "kafka"}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.sink.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("Validate(%s) error = %v, wantErr %v", tt.sink, err, tt.wantErr)
+		}
+	}
+}
+
+func TestLoadRejectsInvalidSink(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+
+	data := "protected_contexts: [prod]\naudit:\n  sinks:\n    - type: syslog\n      network: udp\n"
+	if err := os.WriteFile(filepath.Join(tmpDir, configFileName), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "sink 1") {
+		t.Errorf("Load() error = %v, want one naming sink 1", err)
+	}
+}
+
+func TestLoadRejectsInvalidRedact(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+
+	for _, data := range []string{
+		"protected_contexts: [prod]\nredact:\n  flags: [vault-token]\n",
+		"protected_contexts: [prod]\nredact:\n  flags: [--vault-token=x]\n",
+		"protected_contexts: [prod]\nredact:\n  patterns: ['AKIA[0-9A-Z']\n",
+	} {
+		if err := os.WriteFile(filepath.Join(tmpDir, configFileName), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(); err == nil || !strings.Contains(err.Error(), "redact") {
+			t.Errorf("Load(%q) error = %v, want an invalid redact error", data, err)
+		}
+	}
+}
@@ -42,6 +42,16 @@ func RunSetup(contextNames []string) bool {
 		}
 	}
 
+	// Choose how protected contexts respond to state-altering commands
+	if len(cfg.ProtectedContexts) > 0 {
+		mode, ok := selectMode()
+		if !ok {
+			fmt.Println("Setup cancelled.")
+			return false
+		}
+		cfg.Mode = mode
+	}
+
 	// Save config
 	if err := Save(cfg); err != nil {
 		ui.PrintWarning("Failed to save config: " + err.Error())
@@ -54,6 +64,7 @@ func RunSetup(contextNames []string) bool {
 
 	if len(cfg.ProtectedContexts) > 0 {
 		ui.PrintInfo("Protected: " + strings.Join(cfg.ProtectedContexts, ", "))
+		ui.PrintInfo("Mode: " + string(cfg.Mode))
 	} else {
 		ui.PrintInfo("No contexts protected.")
 	}
@@ -63,3 +74,35 @@ func RunSetup(contextNames []string) bool {
 
 	return true
 }
+
+// modeDescriptions explains each mode offered by the setup wizard.
+var modeDescriptions = map[Mode]string{
+	ModeWarn:    "print a warning and run the command",
+	ModeConfirm: "ask for y/N confirmation",
+	ModeTyped:   "require typing the context name",
+	ModeBlock:   "refuse state-altering commands",
+}
+
+// selectMode asks which protection mode to use, defaulting to confirm.
+// Allow is not offered: it would make protecting the contexts pointless.
+func selectMode() (Mode, bool) {
+	var modes []Mode
+	var options []ui.SelectOption
+	initial := 0
+	for _, m := range Modes {
+		if m == ModeAllow {
+			continue
+		}
+		if m == ModeConfirm {
+			initial = len(modes)
+		}
+		modes = append(modes, m)
+		options = append(options, ui.SelectOption{Name: string(m), Description: modeDescriptions[m]})
+	}
+
+	choice, ok := ui.Select("How should protected contexts handle state-altering commands?", options, initial)
+	if !ok {
+		return "", false
+	}
+	return modes[choice], true
+}
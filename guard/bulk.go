@@ -0,0 +1,108 @@
+package guard
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Bulk describes how a command can act on many resources at once.
+type Bulk struct {
+	// Kinds are the canonical resource kinds the command names.
+	Kinds []string
+	// All is set by --all.
+	All bool
+	// AllNamespaces is set by --all-namespaces/-A.
+	AllNamespaces bool
+	// Selector is the -l/--selector label selector.
+	Selector string
+	// FieldSelector is the --field-selector value.
+	FieldSelector string
+	// Names are the resources named on the command line.
+	Names []string
+	// Directories are -f and -k arguments that are directories.
+	Directories []string
+	// Recursive is set by -R, which also reads subdirectories.
+	Recursive bool
+}
+
+// Bulk returns the bulk indicators of the parsed command.
+func (p ParsedCommand) Bulk() Bulk {
+	c := Classify(p)
+	types, names := resourceArgs(p, c)
+	b := Bulk{
+		Kinds:         normalizeKinds(types),
+		All:           p.Bool("all"),
+		AllNamespaces: p.Bool("all-namespaces"),
+		Names:         names,
+		Recursive:     p.Bool("recursive"),
+	}
+	b.Selector, _ = p.Flag("selector")
+	b.FieldSelector, _ = p.Flag("field-selector")
+
+	for _, f := range p.Flags["filename"] {
+		if info, err := os.Stat(f); err == nil && info.IsDir() {
+			b.Directories = append(b.Directories, f)
+		}
+	}
+	b.Directories = append(b.Directories, p.Flags["kustomize"]...)
+	return b
+}
+
+// IsBulk reports whether the command can act on more than one resource.
+func (b Bulk) IsBulk() bool {
+	return b.All || b.AllNamespaces || b.Selector != "" || b.FieldSelector != "" ||
+		len(b.Names) > 1 || len(b.Directories) > 0
+}
+
+// BlastRadius describes, one line per indicator, what a bulk command run
+// against the target will touch.
+func (b Bulk) BlastRadius(t Target) []string {
+	what := "resources"
+	if len(b.Kinds) > 0 {
+		what = strings.Join(b.Kinds, ", ")
+	}
+	where := "in " + t.NamespaceDescription()
+	if b.clusterScoped() {
+		where = "across the cluster"
+	}
+
+	var lines []string
+	switch {
+	case b.All:
+		lines = append(lines, fmt.Sprintf("every %s %s (--all)", what, where))
+	case b.Selector != "" && b.FieldSelector != "":
+		lines = append(lines, fmt.Sprintf("all %s %s matching labels %q and fields %q", what, where, b.Selector, b.FieldSelector))
+	case b.Selector != "":
+		lines = append(lines, fmt.Sprintf("all %s %s matching labels %q", what, where, b.Selector))
+	case b.FieldSelector != "":
+		lines = append(lines, fmt.Sprintf("all %s %s matching fields %q", what, where, b.FieldSelector))
+	case b.AllNamespaces:
+		lines = append(lines, fmt.Sprintf("%s in every namespace (--all-namespaces)", what))
+	}
+	if len(b.Names) > 1 {
+		lines = append(lines, fmt.Sprintf("%d named resources: %s", len(b.Names), strings.Join(b.Names, ", ")))
+	}
+	for _, dir := range b.Directories {
+		if b.Recursive {
+			lines = append(lines, fmt.Sprintf("every manifest under %s and its subdirectories", dir))
+		} else {
+			lines = append(lines, fmt.Sprintf("every manifest in %s", dir))
+		}
+	}
+	return lines
+}
+
+// clusterScoped reports whether every kind is cluster-scoped, so namespaces
+// don't limit the command.
+func (b Bulk) clusterScoped() bool {
+	if len(b.Kinds) == 0 {
+		return false
+	}
+	for _, kind := range b.Kinds {
+		if !kinds[kind].clusterScoped {
+			return false
+		}
+	}
+	return true
+}
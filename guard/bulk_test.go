@@ -0,0 +1,86 @@
+package guard
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestBulk(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		args []string
+		want bool
+	}{
+		{[]string{"delete", "pod", "nginx"}, false},
+		{[]string{"delete", "pods", "--all", "-n", "prod"}, true},
+		{[]string{"delete", "deploy", "-l", "app=foo", "-A"}, true},
+		{[]string{"label", "nodes", "--all", "zone=a"}, true},
+		{[]string{"delete", "pods", "--field-selector", "status.phase=Failed"}, true},
+		{[]string{"delete", "pod", "a", "b"}, true},
+		{[]string{"delete", "pod/a", "svc/b"}, true},
+		{[]string{"label", "pod", "a", "app=web", "tier-"}, false},
+		{[]string{"apply", "-f", "x.yaml"}, false},
+		{[]string{"apply", "-f", dir}, true},
+		{[]string{"apply", "-k", "overlays/prod"}, true},
+		{[]string{"cordon", "node-1", "node-2"}, true},
+		{[]string{"rollout", "restart", "deploy", "a", "b"}, true},
+		{[]string{"rollout", "restart", "deploy", "a"}, false},
+		{[]string{}, false},
+	}
+
+	for _, tt := range tests {
+		if got := ParseCommand(tt.args).Bulk().IsBulk(); got != tt.want {
+			t.Errorf("Bulk(%v).IsBulk() = %v, want %v", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestBulkIndicators(t *testing.T) {
+	b := ParseCommand([]string{"delete", "deploy", "-l", "app=foo", "-A"}).Bulk()
+	if b.Selector != "app=foo" || !b.AllNamespaces || !slices.Equal(b.Kinds, []string{"deployments"}) {
+		t.Errorf("Bulk() = %+v", b)
+	}
+}
+
+func TestBlastRadius(t *testing.T) {
+	target := Target{Context: "prod", Namespace: "prod"}
+
+	tests := []struct {
+		args   []string
+		target Target
+		want   []string
+	}{
+		{
+			[]string{"delete", "pods", "--all", "-n", "prod"},
+			target,
+			[]string{"every pods in namespace prod (--all)"},
+		},
+		{
+			[]string{"delete", "deploy", "-l", "app=foo", "-A"},
+			Target{Context: "prod", AllNamespaces: true},
+			[]string{`all deployments in all namespaces matching labels "app=foo"`},
+		},
+		{
+			[]string{"label", "nodes", "--all", "zone=a"},
+			target,
+			[]string{"every nodes across the cluster (--all)"},
+		},
+		{
+			[]string{"delete", "pod", "a", "b"},
+			target,
+			[]string{"2 named resources: a, b"},
+		},
+		{
+			[]string{"apply", "-k", "overlays/prod"},
+			target,
+			[]string{"every manifest in overlays/prod"},
+		},
+	}
+
+	for _, tt := range tests {
+		if got := ParseCommand(tt.args).Bulk().BlastRadius(tt.target); !slices.Equal(got, tt.want) {
+			t.Errorf("BlastRadius(%v) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
@@ -2,142 +2,367 @@ package guard
 
 import "strings"
 
-// safeCommands are read-only commands that don't modify cluster state.
-var safeCommands = map[string]bool{
-	"get":           true,
-	"describe":      true,
-	"logs":          true,
-	"top":           true,
-	"explain":       true,
-	"api-resources": true,
-	"api-versions":  true,
-	"version":       true,
-	"cluster-info":  true,
-	"config":        true,
-	"auth":          true,
-	"wait":          true,
-	"diff":          true,
-}
-
-// stateAlteringCommands modify cluster state and require confirmation.
-var stateAlteringCommands = map[string]bool{
-	"apply":     true,
-	"create":    true,
-	"delete":    true,
-	"patch":     true,
-	"replace":   true,
-	"edit":      true,
-	"scale":     true,
-	"rollout":   true,
-	"autoscale": true,
-	"expose":    true,
-	"run":       true,
-	"set":       true,
-	"label":     true,
-	"annotate":  true,
-	"taint":     true,
-	"drain":     true,
-	"cordon":    true,
-	"uncordon":  true,
-	"exec":      true,
-	"cp":        true,
-	"debug":     true,
-	"attach":    true,
-}
-
-// safeRolloutSubcommands are rollout subcommands that don't modify state.
-var safeRolloutSubcommands = map[string]bool{
-	"status":  true,
-	"history": true,
-}
-
-// knownShortFlags are kubectl flags that take a value.
-var knownShortFlags = map[string]bool{
-	"-n": true, "-l": true, "-f": true, "-o": true, "-c": true,
-	"-s": true, "-p": true, "-k": true, "-R": true,
-}
-
-// knownLongFlags are kubectl long flags that take a separate value (not --flag=value style).
-var knownLongFlags = map[string]bool{
-	"--context": true, "--namespace": true, "--selector": true,
-	"--filename": true, "--output": true, "--container": true,
-	"--kubeconfig": true, "--cluster": true, "--user": true,
+// Category describes what a kubectl command can affect.
+type Category string
+
+const (
+	// CategoryRead commands only read cluster state.
+	CategoryRead Category = "read"
+	// CategoryWriteCluster commands modify cluster state.
+	CategoryWriteCluster Category = "write-cluster"
+	// CategoryWriteKubeconfig commands modify the kubeconfig file.
+	CategoryWriteKubeconfig Category = "write-kubeconfig"
+	// CategoryInteractive commands open a session into a workload (exec,
+	// attach, cp, debug) where anything can be changed.
+	CategoryInteractive Category = "interactive"
+	// CategoryNetworkExposure commands expose cluster services locally.
+	CategoryNetworkExposure Category = "network-exposure"
+	// CategoryLocalOnly commands never contact the cluster.
+	CategoryLocalOnly Category = "local-only"
+	// CategoryUnknown commands are not recognised: kubectl plugins, verbs
+	// newer than the classification tree, or typos kubectl may interpret.
+	CategoryUnknown Category = "unknown"
+)
+
+// IsStateAltering reports whether commands in the category can change
+// cluster state, the kubeconfig, or workloads.
+func (c Category) IsStateAltering() bool {
+	switch c {
+	case CategoryWriteCluster, CategoryWriteKubeconfig, CategoryInteractive, CategoryNetworkExposure:
+		return true
+	}
+	return false
 }
 
-// ExtractCommand extracts the kubectl command from args, ignoring flags.
-// Returns the command name and any subcommand.
-func ExtractCommand(args []string) (cmd string, subCmd string) {
-	skipNext := false
-	for _, arg := range args {
-		if skipNext {
-			skipNext = false
-			continue
-		}
+// IsSafe reports whether commands in the category are read-only or local.
+func (c Category) IsSafe() bool {
+	return c == CategoryRead || c == CategoryLocalOnly
+}
 
-		// Skip long flags (--flag or --flag=value)
-		if strings.HasPrefix(arg, "--") {
-			// If it doesn't contain = and is a known flag that takes a value, skip next arg
-			if !strings.Contains(arg, "=") && knownLongFlags[arg] {
-				skipNext = true
-			}
-			continue
+// commandNode is a kubectl command in the classification tree. A node's
+// category applies when no child matches the next argument.
+type commandNode struct {
+	category Category
+	children map[string]*commandNode
+}
+
+func leaf(category Category) *commandNode {
+	return &commandNode{category: category}
+}
+
+func branch(category Category, children map[string]*commandNode) *commandNode {
+	return &commandNode{category: category, children: children}
+}
+
+// commandTree classifies every kubectl command and subcommand (kubectl 1.31).
+var commandTree = map[string]*commandNode{
+	// Basic and deploy commands
+	"create":    leaf(CategoryWriteCluster),
+	"expose":    leaf(CategoryWriteCluster),
+	"run":       leaf(CategoryWriteCluster),
+	"set":       leaf(CategoryWriteCluster),
+	"explain":   leaf(CategoryRead),
+	"get":       leaf(CategoryRead),
+	"edit":      leaf(CategoryWriteCluster),
+	"delete":    leaf(CategoryWriteCluster),
+	"scale":     leaf(CategoryWriteCluster),
+	"autoscale": leaf(CategoryWriteCluster),
+	"rollout": branch(CategoryWriteCluster, map[string]*commandNode{
+		"history": leaf(CategoryRead),
+		"status":  leaf(CategoryRead),
+		"pause":   leaf(CategoryWriteCluster),
+		"restart": leaf(CategoryWriteCluster),
+		"resume":  leaf(CategoryWriteCluster),
+		"undo":    leaf(CategoryWriteCluster),
+	}),
+
+	// Cluster management
+	"certificate": branch(CategoryWriteCluster, map[string]*commandNode{
+		"approve": leaf(CategoryWriteCluster),
+		"deny":    leaf(CategoryWriteCluster),
+	}),
+	"cluster-info": branch(CategoryRead, map[string]*commandNode{
+		"dump": leaf(CategoryRead),
+	}),
+	"top":      leaf(CategoryRead),
+	"cordon":   leaf(CategoryWriteCluster),
+	"uncordon": leaf(CategoryWriteCluster),
+	"drain":    leaf(CategoryWriteCluster),
+	"taint":    leaf(CategoryWriteCluster),
+
+	// Troubleshooting and debugging
+	"describe":     leaf(CategoryRead),
+	"logs":         leaf(CategoryRead),
+	"attach":       leaf(CategoryInteractive),
+	"exec":         leaf(CategoryInteractive),
+	"port-forward": leaf(CategoryNetworkExposure),
+	"proxy":        leaf(CategoryNetworkExposure),
+	"cp":           leaf(CategoryInteractive),
+	"auth": branch(CategoryRead, map[string]*commandNode{
+		"can-i":     leaf(CategoryRead),
+		"whoami":    leaf(CategoryRead),
+		"reconcile": leaf(CategoryWriteCluster),
+	}),
+	"debug":  leaf(CategoryInteractive),
+	"events": leaf(CategoryRead),
+
+	// Advanced commands
+	"diff": leaf(CategoryRead),
+	"apply": branch(CategoryWriteCluster, map[string]*commandNode{
+		"edit-last-applied": leaf(CategoryWriteCluster),
+		"set-last-applied":  leaf(CategoryWriteCluster),
+		"view-last-applied": leaf(CategoryRead),
+	}),
+	"patch":     leaf(CategoryWriteCluster),
+	"replace":   leaf(CategoryWriteCluster),
+	"wait":      leaf(CategoryRead),
+	"kustomize": leaf(CategoryLocalOnly),
+
+	// Settings commands
+	"label":      leaf(CategoryWriteCluster),
+	"annotate":   leaf(CategoryWriteCluster),
+	"completion": leaf(CategoryLocalOnly),
+
+	// Experimental commands may write; treat them as such until classified.
+	"alpha": branch(CategoryWriteCluster, map[string]*commandNode{
+		"diff": leaf(CategoryRead),
+	}),
+
+	// Other commands
+	"api-resources": leaf(CategoryRead),
+	"api-versions":  leaf(CategoryRead),
+	"config": branch(CategoryWriteKubeconfig, map[string]*commandNode{
+		"current-context": leaf(CategoryRead),
+		"get-clusters":    leaf(CategoryRead),
+		"get-contexts":    leaf(CategoryRead),
+		"get-users":       leaf(CategoryRead),
+		"view":            leaf(CategoryRead),
+		// Switching contexts rewrites current-context, which decides where
+		// every later unqualified command runs.
+		"use-context":     leaf(CategoryWriteKubeconfig),
+		"use":             leaf(CategoryWriteKubeconfig),
+		"delete-cluster":  leaf(CategoryWriteKubeconfig),
+		"delete-context":  leaf(CategoryWriteKubeconfig),
+		"delete-user":     leaf(CategoryWriteKubeconfig),
+		"rename-context":  leaf(CategoryWriteKubeconfig),
+		"set":             leaf(CategoryWriteKubeconfig),
+		"set-cluster":     leaf(CategoryWriteKubeconfig),
+		"set-context":     leaf(CategoryWriteKubeconfig),
+		"set-credentials": leaf(CategoryWriteKubeconfig),
+		"unset":           leaf(CategoryWriteKubeconfig),
+	}),
+	"plugin": branch(CategoryLocalOnly, map[string]*commandNode{
+		"list": leaf(CategoryLocalOnly),
+	}),
+	"version": leaf(CategoryRead),
+	"options": leaf(CategoryLocalOnly),
+	"help":    leaf(CategoryLocalOnly),
+}
+
+// Classification is the result of classifying a kubectl command.
+type Classification struct {
+	// Path is the matched command path, e.g. ["rollout", "status"].
+	Path     []string
+	Category Category
+	// DryRun is "client" or "server" when a write was downgraded to a read
+	// because it only simulates the change.
+	DryRun string
+}
+
+// Classify walks the command tree for a parsed command. Running kubectl
+// without a command only prints help, so it is local-only.
+func Classify(p ParsedCommand) Classification {
+	if p.Command == "" {
+		return Classification{Category: CategoryLocalOnly}
+	}
+
+	node, ok := commandTree[p.Command]
+	if !ok {
+		return Classification{Path: []string{p.Command}, Category: CategoryUnknown}
+	}
+
+	path := []string{p.Command}
+	for _, arg := range p.Args {
+		child, ok := node.children[arg]
+		if !ok {
+			break
 		}
+		node = child
+		path = append(path, arg)
+	}
 
-		// Skip short flags
-		if strings.HasPrefix(arg, "-") {
-			// Check if this flag takes a value
-			if knownShortFlags[arg] {
-				skipNext = true
-			}
-			continue
+	c := Classification{Path: path, Category: node.category}
+	if c.Category == CategoryWriteCluster {
+		if mode := dryRunMode(p); mode != "" {
+			c.Category = CategoryRead
+			c.DryRun = mode
 		}
+	}
+	return c
+}
 
-		if cmd == "" {
-			cmd = arg
-		} else {
-			subCmd = arg
-			break
+// dryRunMode returns "client" or "server" if the command only simulates its
+// change. It accepts every form kubectl has supported: --dry-run=client,
+// --dry-run=server, the legacy bare --dry-run and --dry-run=true (client),
+// and the removed --server-dry-run. --dry-run=none and =false are real writes,
+// as is any value kubectl would reject.
+func dryRunMode(p ParsedCommand) string {
+	if value, ok := p.Flag("dry-run"); ok {
+		switch value {
+		case "client", "true", "unchanged":
+			return "client"
+		case "server":
+			return "server"
+		default:
+			return ""
 		}
 	}
-	return
+	if p.Bool("server-dry-run") {
+		return "server"
+	}
+	return ""
 }
 
-// IsSafeCommand returns true if the command is read-only.
-func IsSafeCommand(args []string) bool {
-	if len(args) == 0 {
-		return true
+// ParsedCommand is a kubectl command line split into its parts.
+type ParsedCommand struct {
+	// Command is the top-level kubectl command, e.g. "delete".
+	Command string
+	// Args are the positional arguments after the command.
+	Args []string
+	// Flags maps canonical long flag names (without dashes) to their values
+	// in order of appearance. Boolean flags given bare record "true".
+	Flags map[string][]string
+	// Passthrough holds everything after "--", such as the command for exec.
+	Passthrough []string
+	// Warnings notes flags the parser knows were removed from kubectl.
+	Warnings []string
+}
+
+// ParseCommand parses kubectl args using kubectl's flag grammar, so flag
+// values are never mistaken for the command or its arguments.
+func ParseCommand(args []string) ParsedCommand {
+	p := ParsedCommand{Flags: make(map[string][]string)}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		switch {
+		case arg == "--":
+			p.Passthrough = append([]string{}, args[i+1:]...)
+			return p
+
+		case strings.HasPrefix(arg, "--"):
+			name, value, hasValue := strings.Cut(arg[2:], "=")
+			spec, known := lookupFlag(name, false, p.Command)
+			if !known {
+				// Unknown long flags (plugins, newer kubectl) are assumed
+				// boolean unless given with "=".
+				spec = flagSpec{long: name}
+			}
+			if !hasValue {
+				switch {
+				case spec.value && i+1 < len(args):
+					value = args[i+1]
+					i++
+				case spec.noOptDefault != "":
+					value = spec.noOptDefault
+				default:
+					value = "true"
+				}
+			}
+			p.addFlag(spec, value)
+
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			// Short flags may be grouped ("-it") and may carry an attached
+			// value ("-nprod", "-n=prod").
+			for j := 1; j < len(arg); j++ {
+				spec, known := lookupFlag(arg[j:j+1], true, p.Command)
+				if !known {
+					spec = flagSpec{long: arg[j : j+1]}
+				}
+				if !spec.value {
+					p.addFlag(spec, "true")
+					continue
+				}
+				value := strings.TrimPrefix(arg[j+1:], "=")
+				if value == "" && i+1 < len(args) {
+					value = args[i+1]
+					i++
+				}
+				p.addFlag(spec, value)
+				break
+			}
+
+		default:
+			if p.Command == "" {
+				p.Command = arg
+			} else {
+				p.Args = append(p.Args, arg)
+			}
+		}
 	}
 
-	cmd, subCmd := ExtractCommand(args)
-	if cmd == "" {
-		return true
+	return p
+}
+
+func (p *ParsedCommand) addFlag(spec flagSpec, value string) {
+	p.Flags[spec.long] = append(p.Flags[spec.long], value)
+	if spec.removed != "" {
+		p.Warnings = append(p.Warnings, "--"+spec.long+" was removed in kubectl "+spec.removed)
 	}
+}
 
-	// Special case: rollout status/history are safe
-	if cmd == "rollout" && safeRolloutSubcommands[subCmd] {
-		return true
+// Flag returns the last value given for a flag, by its long name.
+func (p ParsedCommand) Flag(name string) (string, bool) {
+	values := p.Flags[name]
+	if len(values) == 0 {
+		return "", false
 	}
+	return values[len(values)-1], true
+}
 
-	return safeCommands[cmd]
+// Bool reports whether a boolean flag is set and not explicitly false.
+func (p ParsedCommand) Bool(name string) bool {
+	value, ok := p.Flag(name)
+	return ok && value != "false"
 }
 
-// IsStateAltering returns true if the command modifies cluster state.
-func IsStateAltering(args []string) bool {
-	if len(args) == 0 {
-		return false
+// SubCommand returns the first positional argument after the command, which
+// is a subcommand ("rollout restart") or a resource ("get pods").
+func (p ParsedCommand) SubCommand() string {
+	if len(p.Args) == 0 {
+		return ""
 	}
+	return p.Args[0]
+}
 
-	cmd, subCmd := ExtractCommand(args)
-	if cmd == "" {
-		return false
+// ExtractCommand extracts the kubectl command from args, ignoring flags.
+// Returns the command name and any subcommand.
+func ExtractCommand(args []string) (cmd string, subCmd string) {
+	p := ParseCommand(args)
+	return p.Command, p.SubCommand()
+}
+
+// isBuiltinCommand reports whether cmd is a kubectl command rather than a plugin.
+func isBuiltinCommand(cmd string) bool {
+	_, ok := commandTree[cmd]
+	return ok
+}
+
+// IsSafeCommand returns true if the command is read-only.
+func IsSafeCommand(args []string) bool {
+	if len(args) == 0 {
+		return true
 	}
+	return Classify(ParseCommand(args)).Category.IsSafe()
+}
 
-	// Special case: rollout status/history are safe
-	if cmd == "rollout" && safeRolloutSubcommands[subCmd] {
+// IsStateAltering returns true if the command modifies cluster state.
+func IsStateAltering(args []string) bool {
+	if len(args) == 0 {
 		return false
 	}
-
-	return stateAlteringCommands[cmd]
+	return Classify(ParseCommand(args)).Category.IsStateAltering()
 }
 
 // GetCommandDescription returns a human-readable description of the command.
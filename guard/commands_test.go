@@ -1,6 +1,9 @@
 package guard
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 func TestExtractCommand(t *testing.T) {
 	tests := []struct {
@@ -153,6 +156,18 @@ func TestIsStateAltering(t *testing.T) {
 		{"rollout status", []string{"rollout", "status", "deployment/nginx"}, false},
 		{"rollout history", []string{"rollout", "history", "deployment/nginx"}, false},
 
+		// Subcommand-aware classification
+		{"config set-context", []string{"config", "set-context", "prod", "--namespace=x"}, true},
+		{"config delete-context", []string{"config", "delete-context", "prod"}, true},
+		{"auth reconcile", []string{"auth", "reconcile", "-f", "rbac.yaml"}, true},
+		{"apply view-last-applied", []string{"apply", "view-last-applied", "deploy/nginx"}, false},
+		{"certificate approve", []string{"certificate", "approve", "csr-1"}, true},
+		{"port-forward", []string{"port-forward", "svc/nginx", "8080:80"}, true},
+		{"proxy", []string{"proxy"}, true},
+		{"events", []string{"events"}, false},
+		{"kustomize", []string{"kustomize", "."}, false},
+		{"global flag value before delete", []string{"--as", "admin", "delete", "pod", "x"}, true},
+
 		// Edge cases
 		{"empty", []string{}, false},
 		{"delete with flags", []string{"-n", "default", "delete", "pod", "nginx"}, true},
@@ -190,3 +205,190 @@ func TestGetCommandDescription(t *testing.T) {
 		})
 	}
 }
+
+func TestExtractCommandGlobalFlags(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		wantCmd    string
+		wantSubCmd string
+	}{
+		{"as", []string{"--as", "admin", "delete", "pod", "x"}, "delete", "pod"},
+		{"as-group", []string{"--as-group", "system:masters", "delete", "pod"}, "delete", "pod"},
+		{"token", []string{"--token", "abc", "delete", "pod"}, "delete", "pod"},
+		{"server", []string{"--server", "https://prod:6443", "delete", "pod"}, "delete", "pod"},
+		{"short server", []string{"-s", "https://prod:6443", "delete", "pod"}, "delete", "pod"},
+		{"request-timeout", []string{"--request-timeout", "5s", "delete", "pod"}, "delete", "pod"},
+		{"certificate-authority", []string{"--certificate-authority", "/ca.crt", "apply", "-f", "x"}, "apply", ""},
+		{"tls-server-name", []string{"--tls-server-name", "api", "scale", "deploy/x"}, "scale", "deploy/x"},
+		{"verbosity", []string{"-v", "6", "delete", "pod"}, "delete", "pod"},
+		{"attached verbosity", []string{"-v6", "delete", "pod"}, "delete", "pod"},
+		{"boolean does not consume", []string{"--insecure-skip-tls-verify", "delete", "pod"}, "delete", "pod"},
+		{"recursive is boolean", []string{"-R", "apply", "-f", "dir"}, "apply", ""},
+		{"unknown long flag is boolean", []string{"--some-plugin-flag", "delete", "pod"}, "delete", "pod"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, subCmd := ExtractCommand(tt.args)
+			if cmd != tt.wantCmd || subCmd != tt.wantSubCmd {
+				t.Errorf("ExtractCommand(%v) = (%q, %q), want (%q, %q)", tt.args, cmd, subCmd, tt.wantCmd, tt.wantSubCmd)
+			}
+		})
+	}
+}
+
+func TestParseCommand(t *testing.T) {
+	p := ParseCommand([]string{
+		"--context=prod", "-n", "payments", "exec", "-it", "web-0", "-c", "app", "--", "ls", "-la",
+	})
+
+	if p.Command != "exec" {
+		t.Errorf("Command = %q, want exec", p.Command)
+	}
+	if strings.Join(p.Args, " ") != "web-0" {
+		t.Errorf("Args = %v, want [web-0]", p.Args)
+	}
+	if strings.Join(p.Passthrough, " ") != "ls -la" {
+		t.Errorf("Passthrough = %v, want [ls -la]", p.Passthrough)
+	}
+	for name, want := range map[string]string{"context": "prod", "namespace": "payments", "container": "app", "stdin": "true", "tty": "true"} {
+		if got, _ := p.Flag(name); got != want {
+			t.Errorf("Flag(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestParseCommandPerCommandFlags(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		wantArgs string
+		flag     string
+		want     string
+	}{
+		{"logs -f follows", []string{"logs", "-f", "web-0"}, "web-0", "follow", "true"},
+		{"logs -p previous", []string{"logs", "-p", "web-0"}, "web-0", "previous", "true"},
+		{"apply -f filename", []string{"apply", "-f", "x.yaml"}, "", "filename", "x.yaml"},
+		{"patch -p value", []string{"patch", "deploy", "x", "-p", "{}"}, "deploy x", "patch", "{}"},
+		{"proxy -p port", []string{"proxy", "-p", "8001"}, "", "port", "8001"},
+		{"run -l labels", []string{"run", "x", "-l", "app=x", "--image=nginx"}, "x", "labels", "app=x"},
+		{"set -c containers", []string{"set", "image", "-c", "app", "deploy/x", "app=v2"}, "image deploy/x app=v2", "containers", "app"},
+		{"bare dry-run", []string{"apply", "--dry-run", "-f", "x.yaml"}, "", "dry-run", "unchanged"},
+		{"dry-run value", []string{"apply", "--dry-run=server", "-f", "x.yaml"}, "", "dry-run", "server"},
+		{"short attached", []string{"get", "pods", "-npayments"}, "pods", "namespace", "payments"},
+		{"short equals", []string{"get", "pods", "-n=payments"}, "pods", "namespace", "payments"},
+		{"last wins", []string{"-n", "a", "get", "pods", "--namespace=payments"}, "pods", "namespace", "payments"},
+		{"explicit false", []string{"get", "pods", "-A", "--all-namespaces=false"}, "pods", "all-namespaces", "false"},
+		{"stdin filename", []string{"apply", "-f", "-"}, "", "filename", "-"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := ParseCommand(tt.args)
+			if got := strings.Join(p.Args, " "); got != tt.wantArgs {
+				t.Errorf("Args = %q, want %q", got, tt.wantArgs)
+			}
+			if got, _ := p.Flag(tt.flag); got != tt.want {
+				t.Errorf("Flag(%q) = %q, want %q", tt.flag, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCommandSeparator(t *testing.T) {
+	p := ParseCommand([]string{"exec", "x", "--", "kubectl", "--context", "prod", "-A"})
+	if _, ok := p.Flag("context"); ok {
+		t.Error("flags after -- must not be parsed")
+	}
+	if p.Bool("all-namespaces") {
+		t.Error("flags after -- must not be parsed")
+	}
+}
+
+func TestParseCommandRemovedFlags(t *testing.T) {
+	p := ParseCommand([]string{"apply", "--server-dry-run", "-f", "x.yaml"})
+	if len(p.Warnings) != 1 {
+		t.Errorf("Warnings = %v, want one removal warning", p.Warnings)
+	}
+	if got, _ := p.Flag("filename"); got != "x.yaml" {
+		t.Errorf("Flag(filename) = %q, want x.yaml", got)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		args     []string
+		wantPath string
+		want     Category
+	}{
+		{[]string{"get", "pods"}, "get", CategoryRead},
+		{[]string{"config", "set-context", "prod", "--namespace=x"}, "config set-context", CategoryWriteKubeconfig},
+		{[]string{"config", "delete-context", "prod"}, "config delete-context", CategoryWriteKubeconfig},
+		{[]string{"config", "get-contexts"}, "config get-contexts", CategoryRead},
+		{[]string{"config", "use-context", "prod"}, "config use-context", CategoryWriteKubeconfig},
+		{[]string{"config", "use", "prod"}, "config use", CategoryWriteKubeconfig},
+		{[]string{"auth", "reconcile", "-f", "rbac.yaml"}, "auth reconcile", CategoryWriteCluster},
+		{[]string{"auth", "can-i", "delete", "pods"}, "auth can-i", CategoryRead},
+		{[]string{"apply", "view-last-applied", "deploy/x"}, "apply view-last-applied", CategoryRead},
+		{[]string{"apply", "-f", "x.yaml"}, "apply", CategoryWriteCluster},
+		{[]string{"certificate", "approve", "csr-1"}, "certificate approve", CategoryWriteCluster},
+		{[]string{"port-forward", "svc/x", "8080:80"}, "port-forward", CategoryNetworkExposure},
+		{[]string{"proxy", "-p", "8001"}, "proxy", CategoryNetworkExposure},
+		{[]string{"events", "-A"}, "events", CategoryRead},
+		{[]string{"kustomize", "overlays/prod"}, "kustomize", CategoryLocalOnly},
+		{[]string{"plugin", "list"}, "plugin list", CategoryLocalOnly},
+		{[]string{"alpha", "something"}, "alpha", CategoryWriteCluster},
+		{[]string{"exec", "-it", "x", "--", "sh"}, "exec", CategoryInteractive},
+		{[]string{"rollout", "status", "deploy/x"}, "rollout status", CategoryRead},
+		{[]string{"rollout", "restart", "deploy/x"}, "rollout restart", CategoryWriteCluster},
+		{[]string{"--context", "prod"}, "", CategoryLocalOnly},
+		{[]string{"ctx", "prod"}, "ctx", CategoryUnknown},
+		{[]string{"dleete", "pod", "x"}, "dleete", CategoryUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			got := Classify(ParseCommand(tt.args))
+			if strings.Join(got.Path, " ") != tt.wantPath {
+				t.Errorf("Path = %v, want %q", got.Path, tt.wantPath)
+			}
+			if got.Category != tt.want {
+				t.Errorf("Category = %q, want %q", got.Category, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassifyDryRun(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		want       Category
+		wantDryRun string
+	}{
+		{"server", []string{"apply", "-f", "x.yaml", "--dry-run=server"}, CategoryRead, "server"},
+		{"client", []string{"delete", "pod", "x", "--dry-run=client"}, CategoryRead, "client"},
+		{"bare legacy", []string{"create", "deploy", "x", "--image=nginx", "--dry-run", "-o", "yaml"}, CategoryRead, "client"},
+		{"legacy true", []string{"apply", "--dry-run=true", "-f", "x.yaml"}, CategoryRead, "client"},
+		{"legacy server flag", []string{"apply", "--server-dry-run", "-f", "x.yaml"}, CategoryRead, "server"},
+		{"none is a write", []string{"apply", "-f", "x.yaml", "--dry-run=none"}, CategoryWriteCluster, ""},
+		{"false is a write", []string{"apply", "-f", "x.yaml", "--dry-run=false"}, CategoryWriteCluster, ""},
+		{"last value wins", []string{"apply", "--dry-run=server", "--dry-run=none", "-f", "x.yaml"}, CategoryWriteCluster, ""},
+		{"alpha diff", []string{"alpha", "diff", "-f", "x.yaml"}, CategoryRead, ""},
+		{"exec is not downgraded", []string{"exec", "x", "--dry-run=client", "--", "rm", "-rf", "/"}, CategoryInteractive, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Classify(ParseCommand(tt.args))
+			if got.Category != tt.want || got.DryRun != tt.wantDryRun {
+				t.Errorf("Classify(%v) = (%q, dry-run %q), want (%q, dry-run %q)", tt.args, got.Category, got.DryRun, tt.want, tt.wantDryRun)
+			}
+		})
+	}
+
+	if IsStateAltering([]string{"apply", "-f", "x.yaml", "--dry-run=server"}) {
+		t.Error("IsStateAltering(apply --dry-run=server) = true, want false")
+	}
+}
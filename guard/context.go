@@ -2,10 +2,13 @@
 package guard
 
 import (
-	"bufio"
-	"bytes"
-	"os/exec"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
+
+	"github.com/cameronlockhart/kubectl-guard/config"
 )
 
 // KubectlContext represents a kubectl context.
@@ -17,66 +20,225 @@ type KubectlContext struct {
 	Current   bool
 }
 
-// GetCurrentContext returns the current kubectl context name.
-func GetCurrentContext() (string, error) {
-	cmd := exec.Command("kubectl", "config", "current-context")
-	out, err := cmd.Output()
-	if err != nil {
-		return "", err
-	}
-	return strings.TrimSpace(string(out)), nil
+// Target identifies the context a kubectl invocation will actually use.
+type Target struct {
+	Context       string
+	Cluster       string
+	User          string
+	Server        string
+	CAFingerprint string
+	Namespace     string
+	AllNamespaces bool
+	// Namespaces are other namespaces the command affects: Namespace
+	// objects it names and the namespaces of its -f/-k manifests.
+	Namespaces []string
+	// UnknownNamespaces is set when the command has manifests that could
+	// not be inspected, so it may affect any namespace.
+	UnknownNamespaces bool
+	Kubeconfig        string
+
+	// overrides records --cluster/--user flags that replace the context's own values.
+	overrides []string
+	// paths are the kubeconfig files the target was resolved from.
+	paths []string
+	// explicitContext is set when args already name the context.
+	explicitContext bool
 }
 
-// GetAllContexts returns all available kubectl contexts.
-func GetAllContexts() ([]KubectlContext, error) {
-	cmd := exec.Command("kubectl", "config", "get-contexts", "--no-headers")
-	out, err := cmd.Output()
-	if err != nil {
-		return nil, err
+// String returns the context name, noting any cluster or user overrides.
+func (t Target) String() string {
+	if len(t.overrides) == 0 {
+		return t.Context
 	}
+	return t.Context + " (" + strings.Join(t.overrides, ", ") + ")"
+}
 
-	var contexts []KubectlContext
-	scanner := bufio.NewScanner(bytes.NewReader(out))
-	for scanner.Scan() {
-		line := scanner.Text()
-		if line == "" {
-			continue
+// NamespaceDescription describes the namespace the command affects.
+func (t Target) NamespaceDescription() string {
+	if t.AllNamespaces {
+		return "all namespaces"
+	}
+	namespaces := []string{t.Namespace}
+	for _, ns := range t.Namespaces {
+		if !slices.Contains(namespaces, ns) {
+			namespaces = append(namespaces, ns)
 		}
+	}
+	description := "namespaces " + strings.Join(namespaces, ", ")
+	if len(namespaces) == 1 {
+		description = "namespace " + t.Namespace
+	}
+	if t.UnknownNamespaces {
+		description += " and any namespace in uninspected manifests"
+	}
+	return description
+}
+
+// ConfigTarget returns the properties protection rules are matched against.
+func (t Target) ConfigTarget() config.Target {
+	return config.Target{
+		Context:           t.Context,
+		Cluster:           t.Cluster,
+		Server:            t.Server,
+		CAFingerprint:     t.CAFingerprint,
+		Namespace:         t.Namespace,
+		AllNamespaces:     t.AllNamespaces,
+		Namespaces:        t.Namespaces,
+		UnknownNamespaces: t.UnknownNamespaces,
+	}
+}
 
-		ctx := parseContextLine(line)
-		if ctx.Name != "" {
-			contexts = append(contexts, ctx)
+// Pin returns args with the resolved context made explicit, so kubectl runs
+// against the context that was checked even if the kubeconfig's
+// current-context changes before it starts. Plugins and `kubectl config`
+// are left untouched: kubectl rejects flags before a plugin name, and
+// config subcommands operate on the kubeconfig rather than a cluster.
+func (t Target) Pin(args []string) []string {
+	if t.Context == "" || t.explicitContext {
+		return args
+	}
+	cmd, _ := ExtractCommand(args)
+	if cmd == "config" || !isBuiltinCommand(cmd) {
+		return args
+	}
+	return append([]string{"--context=" + t.Context}, args...)
+}
+
+// Environ returns the environment for kubectl with KUBECONFIG pinned to the
+// absolute paths of the files the target was resolved from. An explicit
+// --kubeconfig is already part of the args and needs no pinning.
+func (t Target) Environ() []string {
+	env := os.Environ()
+	if t.Kubeconfig != "" || len(t.paths) == 0 {
+		return env
+	}
+
+	paths := make([]string, len(t.paths))
+	for i, p := range t.paths {
+		if abs, err := filepath.Abs(p); err == nil {
+			p = abs
 		}
+		paths[i] = p
 	}
+	pinned := "KUBECONFIG=" + strings.Join(paths, string(os.PathListSeparator))
 
-	return contexts, scanner.Err()
+	for i, kv := range env {
+		if strings.HasPrefix(kv, "KUBECONFIG=") {
+			env[i] = pinned
+			return env
+		}
+	}
+	return append(env, pinned)
 }
 
-// parseContextLine parses a line from `kubectl config get-contexts --no-headers`.
-// Format: CURRENT   NAME   CLUSTER   AUTHINFO   NAMESPACE
-// CURRENT is * or empty.
-func parseContextLine(line string) KubectlContext {
-	var ctx KubectlContext
+// ResolveTarget determines the context kubectl will use for args. It honours
+// --context, --kubeconfig, --cluster and --user, and otherwise falls back to
+// the current context of the merged kubeconfig (which respects KUBECONFIG).
+func ResolveTarget(args []string) (Target, error) {
+	p := ParseCommand(args)
+	kubeconfig, _ := p.Flag("kubeconfig")
+	kc, err := LoadKubeconfig(kubeconfig)
+	if err != nil {
+		return Target{}, err
+	}
 
-	// Check if this is the current context (starts with *)
-	if strings.HasPrefix(line, "*") {
-		ctx.Current = true
-		line = strings.TrimPrefix(line, "*")
+	name, ok := p.Flag("context")
+	if !ok || name == "" {
+		name = kc.CurrentContext
+	}
+	if name == "" {
+		return Target{}, errors.New("current-context is not set")
 	}
 
-	fields := strings.Fields(line)
-	if len(fields) >= 1 {
-		ctx.Name = fields[0]
+	// An explicit context that isn't defined is left for kubectl to reject;
+	// the context name alone is enough to check protection.
+	t := kc.Target(name)
+	t.Kubeconfig = kubeconfig
+	t.paths = kc.Paths
+	t.explicitContext = ok && name != ""
+
+	if cluster, ok := p.Flag("cluster"); ok && cluster != "" && cluster != t.Cluster {
+		t.Cluster = cluster
+		t.overrides = append(t.overrides, "cluster "+cluster)
+		kc.fillCluster(&t)
+	}
+	if user, ok := p.Flag("user"); ok && user != "" && user != t.User {
+		t.User = user
+		t.overrides = append(t.overrides, "user "+user)
 	}
-	if len(fields) >= 2 {
-		ctx.Cluster = fields[1]
+	if server, ok := p.Flag("server"); ok && server != "" && server != t.Server {
+		t.Server = server
+		t.overrides = append(t.overrides, "server "+server)
 	}
-	if len(fields) >= 3 {
-		ctx.AuthInfo = fields[2]
+	if ca, ok := p.Flag("certificate-authority"); ok && ca != "" {
+		t.CAFingerprint, _ = Cluster{CertificateAuthority: ca}.CAFingerprint()
 	}
-	if len(fields) >= 4 {
-		ctx.Namespace = fields[3]
+
+	// The effective namespace: -A, then -n, then the context's default.
+	if p.Bool("all-namespaces") {
+		t.AllNamespaces = true
+	} else if ns, ok := p.Flag("namespace"); ok && ns != "" {
+		t.Namespace = ns
+	}
+	// Deleting a Namespace object reaches beyond the effective namespace.
+	// Manifests can too, but they are only read when a rule needs them;
+	// see addManifests.
+	t.Namespaces = namedNamespaces(p)
+
+	return t, nil
+}
+
+// namedNamespaces returns the Namespace objects named on the command line,
+// as in "delete namespace payments-prod".
+func namedNamespaces(p ParsedCommand) []string {
+	var out []string
+	types, names := resourceArgs(p, Classify(p))
+	for i, name := range names {
+		// TYPE/NAME arguments each carry their own kind; otherwise every
+		// name is looked up under every TYPE.
+		kinds := types
+		if i < len(types) && slices.Contains(p.Args, types[i]+"/"+name) {
+			kinds = types[i : i+1]
+		}
+		if slices.Contains(normalizeKinds(kinds), "namespaces") && !slices.Contains(out, name) {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+// addManifests adds the namespaces the command's manifests name. Manifests
+// that could not be inspected, such as stdin or URLs, may name any
+// namespace, so they fail closed.
+func (t *Target) addManifests(m Manifests) {
+	for _, ns := range m.Namespaces() {
+		if !slices.Contains(t.Namespaces, ns) {
+			t.Namespaces = append(t.Namespaces, ns)
+		}
+	}
+	if m.Uninspected {
+		t.UnknownNamespaces = true
+	}
+}
+
+// GetAllContexts returns all available kubectl contexts.
+func GetAllContexts() ([]KubectlContext, error) {
+	kc, err := LoadKubeconfig("")
+	if err != nil {
+		return nil, err
+	}
+
+	contexts := make([]KubectlContext, 0, len(kc.Contexts))
+	for _, name := range kc.ContextNames() {
+		c := kc.Contexts[name]
+		contexts = append(contexts, KubectlContext{
+			Name:      c.Name,
+			Cluster:   c.Cluster,
+			AuthInfo:  c.User,
+			Namespace: c.Namespace,
+			Current:   c.Name == kc.CurrentContext,
+		})
 	}
 
-	return ctx
+	return contexts, nil
 }
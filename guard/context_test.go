@@ -1,83 +1,232 @@
 package guard
 
-import "testing"
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestGetAllContexts(t *testing.T) {
+	writeKubeconfigs(t, testKubeconfigA)
+
+	contexts, err := GetAllContexts()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []KubectlContext{
+		{Name: "minikube", Cluster: "minikube", AuthInfo: "minikube"},
+		{Name: "prod", Cluster: "prod-cluster", AuthInfo: "admin", Namespace: "payments", Current: true},
+	}
+	if len(contexts) != len(expected) {
+		t.Fatalf("got %d contexts, want %d", len(contexts), len(expected))
+	}
+	for i, want := range expected {
+		if contexts[i] != want {
+			t.Errorf("contexts[%d] = %+v, want %+v", i, contexts[i], want)
+		}
+	}
+}
+
+func TestResolveTarget(t *testing.T) {
+	writeKubeconfigs(t, testKubeconfigA)
+
+	tests := []struct {
+		name        string
+		args        []string
+		wantContext string
+		wantCluster string
+		wantString  string
+	}{
+		{"current context", []string{"delete", "pod", "x"}, "prod", "prod-cluster", "prod"},
+		{"context flag", []string{"--context", "minikube", "delete", "pod", "x"}, "minikube", "minikube", "minikube"},
+		{"context equals flag", []string{"delete", "pod", "x", "--context=minikube"}, "minikube", "minikube", "minikube"},
+		{"cluster override", []string{"--cluster", "shared", "get", "pods"}, "prod", "shared", "prod (cluster shared)"},
+		{"undefined context", []string{"--context", "nope", "get", "pods"}, "nope", "", "nope"},
+		{"server override", []string{"--server=https://other", "get", "pods"}, "prod", "prod-cluster", "prod (server https://other)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, err := ResolveTarget(tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if target.Context != tt.wantContext {
+				t.Errorf("Context = %q, want %q", target.Context, tt.wantContext)
+			}
+			if target.Cluster != tt.wantCluster {
+				t.Errorf("Cluster = %q, want %q", target.Cluster, tt.wantCluster)
+			}
+			if got := target.String(); got != tt.wantString {
+				t.Errorf("String() = %q, want %q", got, tt.wantString)
+			}
+		})
+	}
+}
+
+func TestResolveTargetNamespace(t *testing.T) {
+	writeKubeconfigs(t, testKubeconfigA)
+	manifest := filepath.Join(t.TempDir(), "app.yaml")
+	data := "kind: Deployment\nmetadata:\n  name: api\n  namespace: payments-prod\n---\nkind: Namespace\nmetadata:\n  name: payments-canary\n---\nkind: ClusterRole\nmetadata:\n  name: reader\n"
+	if err := os.WriteFile(manifest, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"context default", []string{"delete", "pod", "x"}, "namespace payments"},
+		{"no context default", []string{"--context", "minikube", "delete", "pod", "x"}, "namespace default"},
+		{"short flag", []string{"delete", "pod", "x", "-n", "kube-system"}, "namespace kube-system"},
+		{"long flag", []string{"--namespace=kube-system", "delete", "pod", "x"}, "namespace kube-system"},
+		{"all namespaces", []string{"delete", "pods", "--all", "-A", "-n", "ignored"}, "all namespaces"},
+		{"namespace object", []string{"delete", "namespace", "payments-prod", "staging"}, "namespaces payments, payments-prod, staging"},
+		{"namespace type/name", []string{"delete", "ns/payments-prod", "pod/payments"}, "namespaces payments, payments-prod"},
+		{"namespace object in effective namespace", []string{"delete", "ns", "payments"}, "namespace payments"},
+		{"manifests are not read", []string{"apply", "-f", manifest}, "namespace payments"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, err := ResolveTarget(tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := target.NamespaceDescription(); got != tt.want {
+				t.Errorf("NamespaceDescription() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTargetAddManifests(t *testing.T) {
+	writeKubeconfigs(t, testKubeconfigA)
+	manifest := filepath.Join(t.TempDir(), "app.yaml")
+	data := "kind: Deployment\nmetadata:\n  name: api\n  namespace: payments-prod\n---\nkind: Namespace\nmetadata:\n  name: payments-canary\n---\nkind: ClusterRole\nmetadata:\n  name: reader\n"
+	if err := os.WriteFile(manifest, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
 
-func TestParseContextLine(t *testing.T) {
 	tests := []struct {
-		name     string
-		line     string
-		expected KubectlContext
+		name    string
+		args    []string
+		want    string
+		unknown bool
 	}{
-		{
-			name: "current context with all fields",
-			line: "*         prod-cluster       prod-cluster       admin       default",
-			expected: KubectlContext{
-				Name:      "prod-cluster",
-				Cluster:   "prod-cluster",
-				AuthInfo:  "admin",
-				Namespace: "default",
-				Current:   true,
-			},
-		},
-		{
-			name: "non-current context with all fields",
-			line: "          staging            staging-cluster    developer   staging",
-			expected: KubectlContext{
-				Name:      "staging",
-				Cluster:   "staging-cluster",
-				AuthInfo:  "developer",
-				Namespace: "staging",
-				Current:   false,
-			},
-		},
-		{
-			name: "context without namespace",
-			line: "          minikube           minikube           minikube",
-			expected: KubectlContext{
-				Name:     "minikube",
-				Cluster:  "minikube",
-				AuthInfo: "minikube",
-				Current:  false,
-			},
-		},
-		{
-			name: "minimal context",
-			line: "          docker-desktop",
-			expected: KubectlContext{
-				Name:    "docker-desktop",
-				Current: false,
-			},
-		},
-		{
-			name: "eks arn context",
-			line: "*         arn:aws:eks:us-east-1:123456789:cluster/prod   arn:aws:eks:us-east-1:123456789:cluster/prod   admin",
-			expected: KubectlContext{
-				Name:     "arn:aws:eks:us-east-1:123456789:cluster/prod",
-				Cluster:  "arn:aws:eks:us-east-1:123456789:cluster/prod",
-				AuthInfo: "admin",
-				Current:  true,
-			},
-		},
+		{"manifest namespaces", []string{"apply", "-f", manifest}, "namespaces payments, payments-prod, payments-canary", false},
+		{"stdin", []string{"apply", "-f", "-"}, "namespace payments and any namespace in uninspected manifests", true},
+		{"URL", []string{"apply", "-f", manifest, "-f", "https://example.com/app.yaml"}, "namespaces payments, payments-prod, payments-canary and any namespace in uninspected manifests", true},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := parseContextLine(tt.line)
-			if got.Name != tt.expected.Name {
-				t.Errorf("Name = %q, want %q", got.Name, tt.expected.Name)
+			target, err := ResolveTarget(tt.args)
+			if err != nil {
+				t.Fatal(err)
 			}
-			if got.Cluster != tt.expected.Cluster {
-				t.Errorf("Cluster = %q, want %q", got.Cluster, tt.expected.Cluster)
+			target.addManifests(ParseCommand(tt.args).Manifests(nil))
+			if got := target.NamespaceDescription(); got != tt.want {
+				t.Errorf("NamespaceDescription() = %q, want %q", got, tt.want)
 			}
-			if got.AuthInfo != tt.expected.AuthInfo {
-				t.Errorf("AuthInfo = %q, want %q", got.AuthInfo, tt.expected.AuthInfo)
+			if got := target.ConfigTarget().UnknownNamespaces; got != tt.unknown {
+				t.Errorf("UnknownNamespaces = %v, want %v", got, tt.unknown)
 			}
-			if got.Namespace != tt.expected.Namespace {
-				t.Errorf("Namespace = %q, want %q", got.Namespace, tt.expected.Namespace)
+		})
+	}
+}
+
+func TestResolveTargetExplicitContext(t *testing.T) {
+	// An explicit --context is used even when no kubeconfig exists.
+	t.Setenv("KUBECONFIG", "/nonexistent/kubeconfig")
+
+	target, err := ResolveTarget([]string{"--context", "prod-us-east-1", "delete", "pod", "x", "--user=admin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target.Context != "prod-us-east-1" {
+		t.Errorf("Context = %q, want %q", target.Context, "prod-us-east-1")
+	}
+	if target.User != "admin" {
+		t.Errorf("User = %q, want %q", target.User, "admin")
+	}
+	if got, want := target.String(), "prod-us-east-1 (user admin)"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestResolveTargetKubeconfigFlag(t *testing.T) {
+	paths := writeKubeconfigs(t, testKubeconfigA, testKubeconfigB)
+
+	// --kubeconfig replaces KUBECONFIG entirely.
+	target, err := ResolveTarget([]string{"--kubeconfig", paths[1], "apply", "-f", "x.yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target.Context != "staging" {
+		t.Errorf("Context = %q, want %q", target.Context, "staging")
+	}
+	if target.Kubeconfig != paths[1] {
+		t.Errorf("Kubeconfig = %q, want %q", target.Kubeconfig, paths[1])
+	}
+}
+
+func TestTargetPin(t *testing.T) {
+	writeKubeconfigs(t, testKubeconfigA)
+
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{"injects current context", []string{"delete", "pod", "x"}, []string{"--context=prod", "delete", "pod", "x"}},
+		{"keeps explicit context", []string{"--context", "minikube", "delete", "pod", "x"}, []string{"--context", "minikube", "delete", "pod", "x"}},
+		{"skips config", []string{"config", "use-context", "minikube"}, []string{"config", "use-context", "minikube"}},
+		{"skips plugins", []string{"ctx", "minikube"}, []string{"ctx", "minikube"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, err := ResolveTarget(tt.args)
+			if err != nil {
+				t.Fatal(err)
 			}
-			if got.Current != tt.expected.Current {
-				t.Errorf("Current = %v, want %v", got.Current, tt.expected.Current)
+			got := target.Pin(tt.args)
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("Pin(%v) = %v, want %v", tt.args, got, tt.want)
 			}
 		})
 	}
 }
+
+func TestTargetEnviron(t *testing.T) {
+	paths := writeKubeconfigs(t, testKubeconfigA, testKubeconfigB)
+	sep := string(os.PathListSeparator)
+
+	// Relative KUBECONFIG entries are pinned as absolute paths.
+	t.Chdir(filepath.Dir(paths[0]))
+	relative := "KUBECONFIG=" + filepath.Base(paths[0]) + sep + filepath.Base(paths[1])
+	t.Setenv("KUBECONFIG", strings.TrimPrefix(relative, "KUBECONFIG="))
+
+	target, err := ResolveTarget([]string{"delete", "pod", "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "KUBECONFIG=" + strings.Join(paths, sep)
+	if !slices.Contains(target.Environ(), want) {
+		t.Errorf("Environ() missing %q", want)
+	}
+
+	// An explicit --kubeconfig is already pinned by the args.
+	target, err = ResolveTarget([]string{"--kubeconfig", paths[1], "delete", "pod", "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(target.Environ(), relative) {
+		t.Errorf("Environ() should leave KUBECONFIG alone with explicit --kubeconfig")
+	}
+}
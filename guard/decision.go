@@ -0,0 +1,87 @@
+package guard
+
+import (
+	"fmt"
+
+	"github.com/cameronlockhart/kubectl-guard/config"
+)
+
+// Decision is the outcome of checking a command, with everything that led
+// to it, so the prompt, the explain output and the audit log all describe
+// a command the same way.
+type Decision struct {
+	// Action is what to do with the command.
+	Action Result
+	// Args are the kubectl arguments that were checked.
+	Args []string
+	// Command is the parsed command line.
+	Command ParsedCommand
+	// Classification is where the command sits in the command tree.
+	Classification Classification
+	// Target is the context kubectl will use for Args.
+	Target Target
+	// Protected is set when Rule matched the target.
+	Protected bool
+	// Rule is the protection entry that matched the target.
+	Rule config.Rule
+	// Mode is the protection mode applied to the command.
+	Mode config.Mode
+	// Supervise is set when kubectl should run as a child process so its
+	// outcome can be recorded.
+	Supervise bool
+	// Risk is set for state-altering commands.
+	Risk Risk
+	// Bulk describes how many resources the command can reach.
+	Bulk Bulk
+	// Reasons explain the action step by step.
+	Reasons []string
+	// Warnings note anything the check could not take into account.
+	Warnings []string
+
+	// redactor hides secrets when Args are shown or recorded.
+	redactor *Redactor
+}
+
+// RedactedArgs returns Args with secrets hidden, for showing or recording.
+// Use it wherever the arguments leave the process other than to kubectl.
+func (d Decision) RedactedArgs() []string {
+	return d.redactor.Args(d.Args)
+}
+
+// IsBulk reports whether the command on a protected context can act on
+// many resources at once.
+func (d Decision) IsBulk() bool {
+	return d.Protected && d.Bulk.IsBulk()
+}
+
+// BlastRadius describes what a bulk command will touch, or nil.
+func (d Decision) BlastRadius() []string {
+	if !d.IsBulk() {
+		return nil
+	}
+	return d.Bulk.BlastRadius(d.Target)
+}
+
+// Manifests reads the objects named by the command's -f and -k arguments.
+func (d Decision) Manifests(stdin []byte) Manifests {
+	return d.Command.Manifests(stdin)
+}
+
+// because appends a reason.
+func (d *Decision) because(format string, args ...any) {
+	d.Reasons = append(d.Reasons, fmt.Sprintf(format, args...))
+}
+
+// warn appends a warning.
+func (d *Decision) warn(format string, args ...any) {
+	d.Warnings = append(d.Warnings, fmt.Sprintf(format, args...))
+}
+
+// decide records the action, with a final reason if one is given.
+func (d Decision) decide(action Result, reason string) Decision {
+	if reason != "" {
+		d.Reasons = append(d.Reasons, reason)
+	}
+	d.Action = action
+	return d
+}
@@ -0,0 +1,145 @@
+package guard
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/cameronlockhart/kubectl-guard/config"
+)
+
+// Explanation is the printable form of a Decision: what was parsed, which
+// context it resolves to, the rule that matched and why the result follows.
+// Its JSON form is the output of `kubectl-guard explain --json`.
+type Explanation struct {
+	// Args are the kubectl arguments that were checked.
+	Args []string `json:"args"`
+	// Command is the classified command path, e.g. "rollout restart".
+	Command string `json:"command,omitempty"`
+	// Arguments are the positional arguments after the command.
+	Arguments []string `json:"arguments,omitempty"`
+	// Flags are the parsed flags, keyed by long name.
+	Flags map[string][]string `json:"flags,omitempty"`
+
+	Context       string `json:"context,omitempty"`
+	Cluster       string `json:"cluster,omitempty"`
+	Server        string `json:"server,omitempty"`
+	Namespace     string `json:"namespace,omitempty"`
+	AllNamespaces bool   `json:"all_namespaces,omitempty"`
+
+	// Protected is set when a protection rule matches the target.
+	Protected bool `json:"protected"`
+	// Rule describes the matching rule, e.g. "context=prod-*".
+	Rule string `json:"rule,omitempty"`
+	// Mode is the protection mode applied to the command.
+	Mode config.Mode `json:"mode,omitempty"`
+	// Supervise is set when kubectl runs as a child process.
+	Supervise bool `json:"supervise,omitempty"`
+
+	Category Category `json:"category,omitempty"`
+	// DryRun is the --dry-run mode that made a write a read.
+	DryRun      string           `json:"dry_run,omitempty"`
+	Risk        config.RiskLevel `json:"risk,omitempty"`
+	RiskReasons []string         `json:"risk_reasons,omitempty"`
+	// BlastRadius is set for bulk operations.
+	BlastRadius []string `json:"blast_radius,omitempty"`
+
+	// Decision is the result's name, e.g. "confirm".
+	Decision string `json:"decision"`
+	// Reasons explain the decision step by step.
+	Reasons []string `json:"reasons"`
+	// Warnings note anything the check could not take into account.
+	Warnings []string `json:"warnings,omitempty"`
+	// Error is set when the command could not be checked. Safe commands are
+	// still allowed; anything else gets the on_error mode as its decision.
+	Error string `json:"error,omitempty"`
+}
+
+// Explain checks args and describes the decision.
+func Explain(args []string) Explanation {
+	d, err := Check(args)
+	e := d.Explanation()
+	if err != nil {
+		e.Error = err.Error()
+	}
+	return e
+}
+
+// Explanation describes the decision in the form explain prints, with
+// secrets in the arguments and flags redacted.
+func (d Decision) Explanation() Explanation {
+	c := d.Classification
+	args := d.RedactedArgs()
+	// Redaction replaces values in place, so the redacted arguments parse
+	// into the same command with the secrets hidden.
+	parsed := ParseCommand(args)
+	e := Explanation{
+		Args:          args,
+		Command:       strings.Join(c.Path, " "),
+		Arguments:     parsed.Args,
+		Flags:         parsed.Flags,
+		Context:       d.Target.Context,
+		Cluster:       d.Target.Cluster,
+		Server:        d.Target.Server,
+		Namespace:     d.Target.Namespace,
+		AllNamespaces: d.Target.AllNamespaces,
+		Protected:     d.Protected,
+		Mode:          d.Mode,
+		Supervise:     d.Supervise,
+		Category:      c.Category,
+		DryRun:        c.DryRun,
+		Risk:          d.Risk.Level,
+		RiskReasons:   d.Risk.Reasons,
+		BlastRadius:   d.BlastRadius(),
+		Decision:      d.Action.String(),
+		Reasons:       d.Reasons,
+		Warnings:      d.Warnings,
+	}
+	if len(c.Path) > 1 {
+		// Subcommands in the path are the leading args.
+		e.Arguments = parsed.Args[len(c.Path)-1:]
+	}
+	if d.Protected {
+		e.Rule = d.Rule.String()
+	}
+	if e.Reasons == nil {
+		e.Reasons = []string{}
+	}
+	return e
+}
+
+// ExplainsCommand reports whether the arguments after "explain" name a
+// kubectl command for kubectl-guard to explain, rather than a resource for
+// `kubectl explain` (which kubectl-guard passes through). A leading "--"
+// always means a command.
+//
+// Custom resources can share a command's name, such as cert-manager's
+// Certificate and `kubectl certificate`, so resource names win: arguments
+// shaped like `kubectl explain`'s own, a single TYPE[.FIELD] with only the
+// flags it accepts, go to kubectl unless "--" comes first.
+func ExplainsCommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	if args[0] == "--" {
+		return true
+	}
+	cmd, _ := ExtractCommand(args)
+	_, isKind := kinds[kindOf(cmd)]
+	return isBuiltinCommand(cmd) && !isKind && !explainsResource(args)
+}
+
+// explainsResource reports whether args are valid for `kubectl explain`:
+// one TYPE[.FIELD] argument, and only global flags and explain's own.
+func explainsResource(args []string) bool {
+	p := ParseCommand(append([]string{"explain"}, args...))
+	if len(p.Args) != 1 || p.Passthrough != nil {
+		return false
+	}
+	accepted := slices.Concat(globalFlags, commandFlags["explain"])
+	for name := range p.Flags {
+		if !slices.ContainsFunc(accepted, func(spec flagSpec) bool { return spec.long == name }) {
+			return false
+		}
+	}
+	return true
+}
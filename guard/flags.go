@@ -0,0 +1,394 @@
+package guard
+
+// flagSpec describes a kubectl flag for the command-line parser.
+type flagSpec struct {
+	// long is the flag name without dashes; short is the single-letter form.
+	long  string
+	short string
+	// value is true for flags that take a value, either "--flag=v" or "--flag v".
+	value bool
+	// noOptDefault is the value of an optional-value flag given bare (such as
+	// --dry-run), which, like a boolean, never consumes the next argument.
+	noOptDefault string
+	// removed is the kubectl version that dropped the flag. Removed flags stay
+	// in the table so commands written for older clients still parse.
+	removed string
+}
+
+func valueFlag(long, short string) flagSpec { return flagSpec{long: long, short: short, value: true} }
+
+func boolFlag(long, short string) flagSpec { return flagSpec{long: long, short: short} }
+
+func optionalFlag(long, noOptDefault string) flagSpec {
+	return flagSpec{long: long, noOptDefault: noOptDefault}
+}
+
+func removedFlag(spec flagSpec, version string) flagSpec {
+	spec.removed = version
+	return spec
+}
+
+// The tables below follow `kubectl options` and the per-command help of
+// kubectl 1.31, plus flags removed in earlier releases.
+
+// globalFlags are accepted by every kubectl command, before or after the verb.
+var globalFlags = []flagSpec{
+	valueFlag("as", ""),
+	valueFlag("as-group", ""),
+	valueFlag("as-uid", ""),
+	valueFlag("cache-dir", ""),
+	valueFlag("certificate-authority", ""),
+	valueFlag("client-certificate", ""),
+	valueFlag("client-key", ""),
+	valueFlag("cluster", ""),
+	valueFlag("context", ""),
+	boolFlag("disable-compression", ""),
+	boolFlag("insecure-skip-tls-verify", ""),
+	valueFlag("kubeconfig", ""),
+	valueFlag("kuberc", ""),
+	boolFlag("match-server-version", ""),
+	valueFlag("namespace", "n"),
+	valueFlag("password", ""),
+	valueFlag("profile", ""),
+	valueFlag("profile-output", ""),
+	valueFlag("request-timeout", ""),
+	valueFlag("server", "s"),
+	valueFlag("tls-server-name", ""),
+	valueFlag("token", ""),
+	valueFlag("user", ""),
+	valueFlag("username", ""),
+	boolFlag("warnings-as-errors", ""),
+	boolFlag("help", "h"),
+
+	// klog flags
+	valueFlag("v", "v"),
+	valueFlag("vmodule", ""),
+	valueFlag("log-flush-frequency", ""),
+	boolFlag("add-dir-header", ""),
+	boolFlag("alsologtostderr", ""),
+	valueFlag("log-backtrace-at", ""),
+	valueFlag("log-dir", ""),
+	valueFlag("log-file", ""),
+	valueFlag("log-file-max-size", ""),
+	boolFlag("logtostderr", ""),
+	boolFlag("one-output", ""),
+	boolFlag("skip-headers", ""),
+	boolFlag("skip-log-headers", ""),
+	valueFlag("stderrthreshold", ""),
+}
+
+// commonFlags are shared by many commands with the same meaning. They are
+// consulted after any command-specific flags, so commands that reuse a
+// letter differently (logs -f, proxy -p) override them.
+var commonFlags = []flagSpec{
+	// Resource selection
+	valueFlag("filename", "f"),
+	valueFlag("kustomize", "k"),
+	boolFlag("recursive", "R"),
+	valueFlag("selector", "l"),
+	valueFlag("field-selector", ""),
+	boolFlag("all-namespaces", "A"),
+	boolFlag("all", ""),
+	valueFlag("subresource", ""),
+	valueFlag("resource-version", ""),
+	valueFlag("chunk-size", ""),
+	boolFlag("local", ""),
+	valueFlag("raw", ""),
+
+	// Output
+	valueFlag("output", "o"),
+	valueFlag("template", ""),
+	boolFlag("allow-missing-template-keys", ""),
+	boolFlag("show-managed-fields", ""),
+	boolFlag("no-headers", ""),
+	boolFlag("show-labels", ""),
+	boolFlag("show-kind", ""),
+	valueFlag("sort-by", ""),
+	valueFlag("label-columns", "L"),
+	valueFlag("output-directory", ""),
+
+	// Mutation behaviour
+	optionalFlag("dry-run", "unchanged"),
+	optionalFlag("validate", "strict"),
+	optionalFlag("cascade", "background"),
+	valueFlag("field-manager", ""),
+	boolFlag("server-side", ""),
+	boolFlag("force-conflicts", ""),
+	boolFlag("force", ""),
+	valueFlag("grace-period", ""),
+	valueFlag("timeout", ""),
+	boolFlag("wait", ""),
+	boolFlag("now", ""),
+	boolFlag("ignore-not-found", ""),
+	boolFlag("overwrite", ""),
+	boolFlag("save-config", ""),
+	boolFlag("prune", ""),
+	valueFlag("prune-allowlist", ""),
+	valueFlag("prune-whitelist", ""),
+	valueFlag("applyset", ""),
+	boolFlag("openapi-patch", ""),
+	boolFlag("list", ""),
+	boolFlag("record", ""),
+	removedFlag(boolFlag("server-dry-run", ""), "1.23"),
+	removedFlag(boolFlag("export", ""), "1.18"),
+	removedFlag(boolFlag("include-uninitialized", ""), "1.15"),
+	removedFlag(valueFlag("generator", ""), "1.21"),
+	removedFlag(boolFlag("show-all", "a"), "1.11"),
+
+	// Patches and edits
+	valueFlag("patch", "p"),
+	valueFlag("patch-file", ""),
+	valueFlag("type", ""),
+	boolFlag("windows-line-endings", ""),
+	valueFlag("output-patch", ""),
+
+	// Workloads and services
+	valueFlag("image", ""),
+	valueFlag("replicas", ""),
+	valueFlag("current-replicas", ""),
+	valueFlag("container", "c"),
+	valueFlag("env", ""),
+	valueFlag("port", ""),
+	valueFlag("target-port", ""),
+	valueFlag("protocol", ""),
+	valueFlag("name", ""),
+	valueFlag("labels", ""),
+	valueFlag("annotations", ""),
+	valueFlag("overrides", ""),
+	valueFlag("restart", ""),
+	valueFlag("image-pull-policy", ""),
+	valueFlag("command", ""),
+	boolFlag("rm", ""),
+	boolFlag("expose", ""),
+	boolFlag("privileged", ""),
+	boolFlag("stdin", "i"),
+	boolFlag("tty", "t"),
+	boolFlag("quiet", "q"),
+	boolFlag("attach", ""),
+	valueFlag("limits", ""),
+	valueFlag("requests", ""),
+	valueFlag("min", ""),
+	valueFlag("max", ""),
+	valueFlag("cpu-percent", ""),
+	valueFlag("cpu", ""),
+	valueFlag("memory", ""),
+	valueFlag("external-ip", ""),
+	valueFlag("load-balancer-ip", ""),
+	valueFlag("session-affinity", ""),
+	valueFlag("cluster-ip", ""),
+	valueFlag("to-revision", ""),
+	valueFlag("revision", ""),
+	valueFlag("for", ""),
+	valueFlag("pod-running-timeout", ""),
+	valueFlag("address", ""),
+
+	// Nodes
+	valueFlag("pod-selector", ""),
+	boolFlag("ignore-daemonsets", ""),
+	boolFlag("delete-emptydir-data", ""),
+	removedFlag(boolFlag("delete-local-data", ""), "1.25"),
+	boolFlag("disable-eviction", ""),
+	valueFlag("skip-wait-for-delete-timeout", ""),
+
+	// create subcommands
+	valueFlag("from", ""),
+	valueFlag("from-file", ""),
+	valueFlag("from-literal", ""),
+	valueFlag("from-env-file", ""),
+	boolFlag("append-hash", ""),
+	valueFlag("docker-server", ""),
+	valueFlag("docker-username", ""),
+	valueFlag("docker-password", ""),
+	valueFlag("docker-email", ""),
+	valueFlag("cert", ""),
+	valueFlag("key", ""),
+	valueFlag("clusterrole", ""),
+	valueFlag("role", ""),
+	valueFlag("serviceaccount", ""),
+	valueFlag("group", ""),
+	valueFlag("verb", ""),
+	valueFlag("resource", ""),
+	valueFlag("resource-name", ""),
+	valueFlag("non-resource-url", ""),
+	valueFlag("aggregation-rule", ""),
+	valueFlag("schedule", ""),
+	valueFlag("duration", ""),
+	valueFlag("audience", ""),
+	valueFlag("bound-object-kind", ""),
+	valueFlag("bound-object-name", ""),
+	valueFlag("bound-object-uid", ""),
+	valueFlag("rule", ""),
+	valueFlag("class", ""),
+	valueFlag("default-backend", ""),
+	valueFlag("annotation", ""),
+	valueFlag("tcp", ""),
+	valueFlag("node-port", ""),
+	valueFlag("external-name", ""),
+	valueFlag("clusterip", ""),
+	valueFlag("hard", ""),
+	valueFlag("scopes", ""),
+	valueFlag("value", ""),
+	valueFlag("description", ""),
+	boolFlag("global-default", ""),
+	valueFlag("preemption-policy", ""),
+	valueFlag("min-available", ""),
+	valueFlag("max-unavailable", ""),
+
+	// Discovery and explain
+	valueFlag("api-group", ""),
+	valueFlag("api-version", ""),
+	boolFlag("namespaced", ""),
+	valueFlag("verbs", ""),
+	boolFlag("cached", ""),
+}
+
+// commandFlags lists flags whose meaning differs from commonFlags, or that
+// only exist on one command, keyed by top-level command.
+var commandFlags = map[string][]flagSpec{
+	"logs": {
+		boolFlag("follow", "f"),
+		boolFlag("previous", "p"),
+		boolFlag("all-containers", ""),
+		boolFlag("prefix", ""),
+		boolFlag("timestamps", ""),
+		boolFlag("ignore-errors", ""),
+		valueFlag("since", ""),
+		valueFlag("since-time", ""),
+		valueFlag("tail", ""),
+		valueFlag("limit-bytes", ""),
+		valueFlag("max-log-requests", ""),
+	},
+	"get": {
+		boolFlag("watch", "w"),
+		boolFlag("watch-only", ""),
+		boolFlag("output-watch-events", ""),
+		boolFlag("ignore-not-found", ""),
+	},
+	"events": {
+		boolFlag("watch", "w"),
+		valueFlag("types", ""),
+	},
+	"rollout": {
+		boolFlag("watch", "w"),
+	},
+	"delete": {
+		boolFlag("interactive", "i"),
+	},
+	"set": {
+		valueFlag("containers", "c"),
+		valueFlag("env", "e"),
+		valueFlag("from", ""),
+		valueFlag("prefix", ""),
+		valueFlag("keys", ""),
+		boolFlag("resolve", ""),
+		valueFlag("serviceaccount", ""),
+	},
+	"top": {
+		boolFlag("containers", ""),
+		boolFlag("sum", ""),
+		boolFlag("use-protocol-buffers", ""),
+		boolFlag("show-capacity", ""),
+	},
+	"run": {
+		valueFlag("labels", "l"),
+	},
+	"cp": {
+		boolFlag("no-preserve", ""),
+		valueFlag("retries", ""),
+	},
+	"debug": {
+		valueFlag("copy-to", ""),
+		valueFlag("target", ""),
+		valueFlag("profile", ""),
+		valueFlag("custom", ""),
+		valueFlag("set-image", ""),
+		valueFlag("env", ""),
+		boolFlag("share-processes", ""),
+		boolFlag("same-node", ""),
+		boolFlag("replace", ""),
+	},
+	"proxy": {
+		valueFlag("port", "p"),
+		valueFlag("www", "w"),
+		valueFlag("www-prefix", "P"),
+		valueFlag("unix-socket", "u"),
+		valueFlag("api-prefix", ""),
+		valueFlag("accept-hosts", ""),
+		valueFlag("accept-paths", ""),
+		valueFlag("reject-paths", ""),
+		valueFlag("reject-methods", ""),
+		valueFlag("keepalive", ""),
+		boolFlag("disable-filter", ""),
+		boolFlag("append-server-path", ""),
+	},
+	"config": {
+		boolFlag("raw", ""),
+		boolFlag("minify", ""),
+		boolFlag("flatten", ""),
+		optionalFlag("merge", "true"),
+		boolFlag("current", ""),
+		boolFlag("set-raw-bytes", ""),
+		optionalFlag("embed-certs", "true"),
+		valueFlag("auth-provider", ""),
+		valueFlag("auth-provider-arg", ""),
+		valueFlag("exec-command", ""),
+		valueFlag("exec-api-version", ""),
+		valueFlag("exec-arg", ""),
+		valueFlag("exec-env", ""),
+		valueFlag("exec-interactive-mode", ""),
+		boolFlag("exec-provide-cluster-info", ""),
+		valueFlag("proxy-url", ""),
+	},
+	"auth": {
+		boolFlag("remove-extra-permissions", ""),
+		boolFlag("remove-extra-subjects", ""),
+	},
+	"version": {
+		boolFlag("client", ""),
+		removedFlag(boolFlag("short", ""), "1.28"),
+	},
+	"explain": {
+		boolFlag("recursive", ""),
+		valueFlag("api-version", ""),
+		valueFlag("output", "o"),
+	},
+	"cluster-info": {
+		valueFlag("namespaces", ""),
+	},
+	"plugin": {
+		boolFlag("name-only", ""),
+	},
+	"kustomize": {
+		valueFlag("env", "e"),
+		boolFlag("enable-helm", ""),
+		valueFlag("helm-command", ""),
+		valueFlag("load-restrictor", ""),
+		boolFlag("enable-alpha-plugins", ""),
+		boolFlag("enable-exec", ""),
+		valueFlag("mount", ""),
+		boolFlag("network", ""),
+		valueFlag("network-name", ""),
+		boolFlag("as-current-user", ""),
+		boolFlag("reorder", ""),
+	},
+	"completion": {},
+}
+
+// lookupFlag finds the spec for a long name or single-letter short name,
+// consulting global flags, then the command's own flags, then common flags.
+func lookupFlag(name string, short bool, command string) (flagSpec, bool) {
+	tables := [][]flagSpec{globalFlags, commandFlags[command], commonFlags}
+	if command == "" {
+		// Before the verb only global flags are valid, but fall back to the
+		// common table so misplaced flags still parse sensibly.
+		tables = [][]flagSpec{globalFlags, commonFlags}
+	}
+	for _, table := range tables {
+		for _, spec := range table {
+			if (short && spec.short == name) || (!short && spec.long == name) {
+				return spec, true
+			}
+		}
+	}
+	return flagSpec{}, false
+}
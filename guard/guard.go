@@ -1,8 +1,10 @@
 package guard
 
 import (
-	"os"
+	"fmt"
 	"os/exec"
+	"strings"
+	"sync"
 	"syscall"
 
 	"github.com/cameronlockhart/kubectl-guard/config"
@@ -18,47 +20,186 @@ const (
 	RequireConfirmation
 	// SetupRequired means the config doesn't exist and setup is needed.
 	SetupRequired
+	// Warn means the command should run after printing a warning.
+	Warn
+	// RequireTypedConfirmation means the user must type the context name.
+	RequireTypedConfirmation
+	// Block means the command must not run.
+	Block
 )
 
-// Check evaluates whether a command should be allowed, require confirmation, or trigger setup.
-func Check(args []string) (Result, string, error) {
+// String returns the name of the result, e.g. "confirm".
+func (r Result) String() string {
+	switch r {
+	case Allow:
+		return "allow"
+	case RequireConfirmation:
+		return "confirm"
+	case SetupRequired:
+		return "setup"
+	case Warn:
+		return "warn"
+	case RequireTypedConfirmation:
+		return "typed"
+	case Block:
+		return "block"
+	}
+	return "unknown"
+}
+
+// resultForMode maps a protection mode to the result for a guarded command.
+func resultForMode(mode config.Mode) Result {
+	switch mode {
+	case config.ModeAllow:
+		return Allow
+	case config.ModeWarn:
+		return Warn
+	case config.ModeTyped:
+		return RequireTypedConfirmation
+	case config.ModeBlock:
+		return Block
+	default:
+		return RequireConfirmation
+	}
+}
+
+// Check evaluates whether a command should be allowed, warned about, confirmed,
+// blocked, or trigger setup, and records why. An error means the config or
+// kubeconfig couldn't be read; the decision then follows the on_error mode.
+func Check(args []string) (Decision, error) {
+	p := ParseCommand(args)
+	c := Classify(p)
+	d := Decision{
+		Action:         Allow,
+		Args:           args,
+		Command:        p,
+		Classification: c,
+		Warnings:       p.Warnings,
+	}
+
 	// Check if config exists
 	exists, err := config.Exists()
 	if err != nil {
-		return Allow, "", err
+		return d.failed(err, config.OnErrorMode(nil))
 	}
 	if !exists {
-		return SetupRequired, "", nil
+		return d.decide(SetupRequired, "no configuration file exists, so the setup wizard runs"), nil
 	}
 
 	// Load config
 	cfg, err := config.Load()
 	if err != nil {
-		return Allow, "", err
+		return d.failed(err, config.OnErrorMode(nil))
+	}
+
+	if r, err := NewRedactor(cfg.Redact); err == nil {
+		d.redactor = r
 	}
 
-	// Get current context
-	ctx, err := GetCurrentContext()
+	// Resolve the context kubectl will actually use. Without it there is
+	// no telling whether the context is protected.
+	target, err := ResolveTarget(args)
 	if err != nil {
-		// If we can't get context, allow the command (kubectl will handle errors)
-		return Allow, "", nil
+		return d.failed(fmt.Errorf("could not resolve the context: %w", err), config.OnErrorMode(cfg))
+	}
+	// Manifests can bring the command under a rule for another namespace.
+	// Decoding them is the slowest part of a check, so they are only read
+	// when such a rule exists for this cluster.
+	manifests := sync.OnceValue(func() Manifests { return p.Manifests(nil) })
+	if cfg.LimitedToOtherNamespaces(target.ConfigTarget()) {
+		target.addManifests(manifests())
 	}
+	d.Target = target
 
-	// Check if context is protected
-	if !cfg.IsContextProtected(ctx) {
-		return Allow, ctx, nil
+	// Check if the target cluster is protected
+	rules := cfg.Match(target.ConfigTarget())
+	if len(rules) == 0 {
+		return d.decide(Allow, fmt.Sprintf("context %s matches no protection rule", target)), nil
+	}
+	d.Protected = true
+	d.Rule = rules[0]
+	// Only commands that are recorded have an outcome worth waiting for.
+	d.Supervise = cfg.Supervise && !c.Category.IsSafe()
+	d.Bulk = p.Bulk()
+	if len(rules) == 1 {
+		d.because("context %s in %s matches rule %s", target, target.NamespaceDescription(), d.Rule)
+	} else {
+		d.because("context %s in %s matches rules %s", target, target.NamespaceDescription(), joinRules(rules))
 	}
 
-	// Context is protected - check if command is state-altering
-	if IsStateAltering(args) {
-		return RequireConfirmation, ctx, nil
+	// Context is protected - check how the command is classified
+	command := commandName(c)
+	switch {
+	case c.Category == CategoryUnknown:
+		d.strictest(rules, cfg.UnknownModeFor)
+		// An unknown command may be the value of a flag kubectl doesn't
+		// know, so name it from the redacted arguments.
+		command = commandName(Classify(ParseCommand(d.RedactedArgs())))
+		d.because("%q is not a recognised kubectl command; unknown_commands mode is %s", command, d.Mode)
+		return d.decide(resultForMode(d.Mode), ""), nil
+	case c.Category.IsStateAltering():
+		d.Risk = AssessRisk(p, c, manifests())
+		d.strictest(rules, func(r config.Rule) config.Mode { return cfg.ModeForRisk(r, d.Risk.Level) })
+		d.because("%s is %s, which alters state", command, c.Category)
+		d.because("it is %s; the mode for that is %s", d.Risk, d.Mode)
+		result := resultForMode(d.Mode)
+		// Bulk operations always get a confirmation, never just a warning.
+		if result == Warn && d.Bulk.IsBulk() {
+			result = RequireConfirmation
+			d.because("bulk operations need confirmation even in warn mode")
+		}
+		return d.decide(result, ""), nil
+	case c.DryRun != "":
+		return d.decide(Allow, fmt.Sprintf("--dry-run=%s only simulates the change, so it is a read", c.DryRun)), nil
 	}
+	return d.decide(Allow, fmt.Sprintf("%s is %s, which runs without prompts", command, c.Category)), nil
+}
 
-	return Allow, ctx, nil
+// strictest applies the strictest mode the matching rules give the command,
+// noting which rule set it when there is a choice.
+func (d *Decision) strictest(rules []config.Rule, modeFor func(config.Rule) config.Mode) {
+	d.Rule, d.Mode = config.Strictest(rules, modeFor)
+	if len(rules) > 1 {
+		d.because("rule %s is the strictest of them", d.Rule)
+	}
 }
 
-// ExecKubectl replaces the current process with kubectl.
-func ExecKubectl(args []string) error {
+// joinRules lists rules for a reason, e.g. "context=prod; namespaces=kube-system".
+func joinRules(rules []config.Rule) string {
+	names := make([]string, len(rules))
+	for i, r := range rules {
+		names[i] = r.String()
+	}
+	return strings.Join(names, "; ")
+}
+
+// failed decides a command that couldn't be checked by the on_error mode.
+// Safe commands run regardless, since they can't change anything.
+func (d Decision) failed(err error, mode config.Mode) (Decision, error) {
+	d.Mode = mode
+	d.warn("%v", err)
+	if d.Classification.Category.IsSafe() {
+		return d.decide(Allow, fmt.Sprintf("the command could not be checked, but %s is %s", commandName(d.Classification), d.Classification.Category)), err
+	}
+	return d.decide(resultForMode(mode), fmt.Sprintf("the command could not be checked; on_error mode is %s", mode)), err
+}
+
+// commandName names a classified command for reasons, e.g. "rollout restart".
+func commandName(c Classification) string {
+	if len(c.Path) == 0 {
+		return "kubectl without a command"
+	}
+	return strings.Join(c.Path, " ")
+}
+
+// ExecTarget replaces the current process with kubectl, pinned to the target
+// that was checked so the command cannot reach a different cluster than the
+// one the decision was made for.
+func ExecTarget(args []string, t Target) error {
+	return execKubectl(t.Pin(args), t.Environ())
+}
+
+func execKubectl(args []string, env []string) error {
 	kubectl, err := exec.LookPath("kubectl")
 	if err != nil {
 		return err
@@ -67,11 +208,5 @@ func ExecKubectl(args []string) error {
 	// Prepend "kubectl" to args for proper argv[0]
 	fullArgs := append([]string{"kubectl"}, args...)
 
-	return syscall.Exec(kubectl, fullArgs, os.Environ())
-}
-
-// RunKubectl runs kubectl and returns its output.
-func RunKubectl(args ...string) ([]byte, error) {
-	cmd := exec.Command("kubectl", args...)
-	return cmd.Output()
+	return syscall.Exec(kubectl, fullArgs, env)
 }
@@ -2,6 +2,7 @@ package guard
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/cameronlockhart/kubectl-guard/config"
@@ -21,12 +22,12 @@ func TestCheck(t *testing.T) {
 
 	// Test: No config file -> SetupRequired
 	t.Run("no config requires setup", func(t *testing.T) {
-		result, _, err := Check([]string{"get", "pods"})
+		d, err := Check([]string{"get", "pods"})
 		if err != nil {
 			t.Fatal(err)
 		}
-		if result != SetupRequired {
-			t.Errorf("Check() = %v, want SetupRequired", result)
+		if d.Action != SetupRequired {
+			t.Errorf("Check() = %v, want SetupRequired", d.Action)
 		}
 	})
 
@@ -56,3 +57,328 @@ func TestCheck(t *testing.T) {
 		}
 	})
 }
+
+func TestCheckModes(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	writeKubeconfigs(t, testKubeconfigA)
+
+	cfg := &config.Config{
+		ProtectedContexts: []string{"prod"},
+		Rules: []config.Rule{
+			{Context: "minikube", Mode: config.ModeBlock},
+		},
+		Mode: config.ModeTyped,
+	}
+	if err := config.Save(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want Result
+	}{
+		{"read on protected", []string{"get", "pods"}, Allow},
+		{"default mode", []string{"delete", "pod", "x"}, RequireTypedConfirmation},
+		{"rule mode", []string{"--context", "minikube", "delete", "pod", "x"}, Block},
+		{"unprotected", []string{"--context", "staging", "delete", "pod", "x"}, Allow},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := Check(tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d.Action != tt.want {
+				t.Errorf("Check(%v) = %v, want %v", tt.args, d.Action, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckOverlappingRules(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	writeKubeconfigs(t, testKubeconfigA)
+
+	// protected_contexts come first, but the stricter rule still applies.
+	cfg := &config.Config{
+		ProtectedContexts: []string{"prod"},
+		Rules: []config.Rule{
+			{Namespaces: []string{"payments"}, Mode: config.ModeBlock},
+			{Context: "prod", UnknownCommands: config.ModeTyped, RiskModes: map[config.RiskLevel]config.Mode{config.RiskCritical: config.ModeAllow}},
+		},
+		Mode: config.ModeConfirm,
+	}
+	if err := config.Save(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want Result
+		rule string
+	}{
+		{"namespace rule", []string{"delete", "pod", "x"}, Block, "namespaces=payments mode=block"},
+		{"permissive risk mode", []string{"delete", "namespace", "web", "-n", "web"}, RequireConfirmation, "context=prod"},
+		{"unknown command", []string{"frobnicate", "-n", "web"}, RequireTypedConfirmation, "context=prod unknown_commands=typed risk_modes.critical=allow"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := Check(tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d.Action != tt.want || d.Rule.String() != tt.rule {
+				t.Errorf("Check(%v) = %v by %s, want %v by %s", tt.args, d.Action, d.Rule, tt.want, tt.rule)
+			}
+		})
+	}
+}
+
+func TestCheckAffectedNamespaces(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	writeKubeconfigs(t, testKubeconfigA)
+
+	cfg := &config.Config{
+		Rules: []config.Rule{{Namespaces: []string{"payments-prod"}, Mode: config.ModeBlock}},
+	}
+	if err := config.Save(cfg); err != nil {
+		t.Fatal(err)
+	}
+	manifest := filepath.Join(t.TempDir(), "app.yaml")
+	if err := os.WriteFile(manifest, []byte("kind: Deployment\nmetadata:\n  name: api\n  namespace: payments-prod\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want Result
+	}{
+		{"other namespace", []string{"delete", "pod", "x"}, Allow},
+		{"namespace object", []string{"delete", "namespace", "payments-prod"}, Block},
+		{"namespace type/name", []string{"delete", "ns/payments-prod"}, Block},
+		{"manifest namespace", []string{"apply", "-f", manifest}, Block},
+		{"stdin manifests", []string{"apply", "-f", "-"}, Block},
+		{"URL manifests", []string{"apply", "-f", "https://example.com/payments.yaml"}, Block},
+		{"remote kustomization", []string{"apply", "-k", "https://github.com/example/payments//prod"}, Block},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := Check(tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d.Action != tt.want {
+				t.Errorf("Check(%v) = %v, want %v", tt.args, d.Action, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckUnknownCommands(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	writeKubeconfigs(t, testKubeconfigA)
+
+	cfg := &config.Config{
+		ProtectedContexts: []string{"prod"},
+		Rules: []config.Rule{
+			{Context: "minikube", UnknownCommands: config.ModeBlock},
+		},
+	}
+	if err := config.Save(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	// Unknown commands are allowed by default, preserving plugin behaviour.
+	if d, err := Check([]string{"ctx", "staging"}); err != nil || d.Action != Allow {
+		t.Errorf("Check(plugin) = (%v, %v), want Allow", d.Action, err)
+	}
+	if d, err := Check([]string{"--context", "minikube", "ctx"}); err != nil || d.Action != Block {
+		t.Errorf("Check(plugin on fail-closed rule) = (%v, %v), want Block", d.Action, err)
+	}
+
+	cfg.UnknownCommands = config.ModeConfirm
+	if err := config.Save(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if d, err := Check([]string{"dleete", "pod", "x"}); err != nil || d.Action != RequireConfirmation {
+		t.Errorf("Check(typo) = (%v, %v), want RequireConfirmation", d.Action, err)
+	}
+	// Recognised reads are unaffected by the unknown-command policy.
+	if d, err := Check([]string{"get", "pods"}); err != nil || d.Action != Allow {
+		t.Errorf("Check(get) = (%v, %v), want Allow", d.Action, err)
+	}
+}
+
+func TestCheckRiskModes(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	writeKubeconfigs(t, testKubeconfigA)
+
+	cfg := &config.Config{
+		ProtectedContexts: []string{"prod"},
+		RiskModes: map[config.RiskLevel]config.Mode{
+			config.RiskLow:      config.ModeWarn,
+			config.RiskCritical: config.ModeTyped,
+		},
+	}
+	if err := config.Save(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		args []string
+		want Result
+	}{
+		{[]string{"delete", "pod", "nginx-abc"}, Warn},
+		{[]string{"delete", "deployment", "web"}, RequireConfirmation},
+		{[]string{"delete", "namespace", "payments"}, RequireTypedConfirmation},
+	}
+	for _, tt := range tests {
+		d, err := Check(tt.args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.Action != tt.want {
+			t.Errorf("Check(%v) = %v, want %v", tt.args, d.Action, tt.want)
+		}
+	}
+}
+
+func TestCheckBulkEscalates(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	writeKubeconfigs(t, testKubeconfigA)
+
+	cfg := &config.Config{ProtectedContexts: []string{"prod"}, Mode: config.ModeWarn}
+	if err := config.Save(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if d, err := Check([]string{"delete", "pod", "x"}); err != nil || d.Action != Warn {
+		t.Errorf("Check(delete pod) = (%v, %v), want Warn", d.Action, err)
+	}
+	if d, err := Check([]string{"delete", "pods", "--all"}); err != nil || d.Action != RequireConfirmation {
+		t.Errorf("Check(delete pods --all) = (%v, %v), want RequireConfirmation", d.Action, err)
+	}
+}
+
+func TestCheckDecision(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	writeKubeconfigs(t, testKubeconfigA)
+
+	cfg := &config.Config{
+		Rules: []config.Rule{{Cluster: "prod-cluster", Mode: config.ModeTyped}},
+	}
+	if err := config.Save(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := Check([]string{"delete", "pods", "-l", "app=web", "--generator", "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Action != RequireTypedConfirmation || d.Mode != config.ModeTyped {
+		t.Errorf("action = %v (mode %s), want typed", d.Action, d.Mode)
+	}
+	if !d.Protected || d.Rule.Cluster != "prod-cluster" {
+		t.Errorf("rule = %v (protected %v), want cluster=prod-cluster", d.Rule, d.Protected)
+	}
+	if d.Target.Context != "prod" || d.Target.Namespace != "payments" {
+		t.Errorf("target = %s in %s, want prod in payments", d.Target.Context, d.Target.Namespace)
+	}
+	if d.Command.Command != "delete" || d.Classification.Category != CategoryWriteCluster {
+		t.Errorf("command = %s (%s), want delete (write-cluster)", d.Command.Command, d.Classification.Category)
+	}
+	if d.Risk.Level != config.RiskMedium || !d.IsBulk() || len(d.BlastRadius()) != 1 {
+		t.Errorf("risk = %s, bulk = %v, blast radius = %q", d.Risk, d.IsBulk(), d.BlastRadius())
+	}
+	if len(d.Reasons) == 0 {
+		t.Error("Reasons is empty")
+	}
+	if d.Supervise {
+		t.Error("Supervise = true, want false unless configured")
+	}
+	if len(d.Warnings) != 1 {
+		t.Errorf("Warnings = %q, want the removed --generator flag", d.Warnings)
+	}
+
+	// A kubeconfig that can't be read is reported, and decided by on_error.
+	writeKubeconfigs(t, "contexts: [")
+	d, err = Check([]string{"delete", "pod", "x"})
+	if err == nil {
+		t.Fatal("Check with an unreadable kubeconfig: error = nil")
+	}
+	if d.Action != Warn || d.Protected || len(d.Warnings) != 1 {
+		t.Errorf("unreadable kubeconfig: action = %v, protected = %v, warnings = %q", d.Action, d.Protected, d.Warnings)
+	}
+}
+
+func TestCheckOnError(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv(config.EnvOnError, "")
+	writeKubeconfigs(t, testKubeconfigA)
+
+	path := filepath.Join(home, ".kubectl-guard.yaml")
+	write := func(data string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		config string
+		env    string
+		args   []string
+		want   Result
+	}{
+		{"corrupt config warns by default", "protected_contexts: [prod", "", []string{"delete", "pod", "x"}, Warn},
+		{"reads still run", "protected_contexts: [prod", "", []string{"get", "pods"}, Allow},
+		{"env overrides a corrupt config", "protected_contexts: [prod", "block", []string{"delete", "pod", "x"}, Block},
+		{"on_error read from an invalid config", "protected_contexts: [prod]\nmode: sometimes\non_error: confirm\n", "", []string{"apply", "-f", "x.yaml"}, RequireConfirmation},
+		{"unknown commands are not safe", "protected_contexts: [prod]\nmode: sometimes\non_error: block\n", "", []string{"frobnicate"}, Block},
+		{"allow restores pass-through", "protected_contexts: [prod", "allow", []string{"delete", "pod", "x"}, Allow},
+		{"invalid env is ignored", "protected_contexts: [prod", "maybe", []string{"delete", "pod", "x"}, Warn},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			write(tt.config)
+			t.Setenv(config.EnvOnError, tt.env)
+			d, err := Check(tt.args)
+			if err == nil {
+				t.Fatal("Check() error = nil, want the config error")
+			}
+			if d.Action != tt.want {
+				t.Errorf("Check(%q) = %v, want %v", tt.args, d.Action, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckSupervise(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	writeKubeconfigs(t, testKubeconfigA)
+
+	cfg := &config.Config{ProtectedContexts: []string{"prod"}, Supervise: true}
+	if err := config.Save(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if d, err := Check([]string{"delete", "pod", "x"}); err != nil || !d.Supervise {
+		t.Errorf("Check(protected) Supervise = %v (%v), want true", d.Supervise, err)
+	}
+	// Unprotected contexts keep handing over to kubectl directly.
+	if d, err := Check([]string{"--context", "minikube", "delete", "pod", "x"}); err != nil || d.Supervise {
+		t.Errorf("Check(unprotected) Supervise = %v (%v), want false", d.Supervise, err)
+	}
+	// So do reads, which aren't recorded.
+	for _, args := range [][]string{{"get", "pods", "-w"}, {"delete", "pod", "x", "--dry-run=server"}} {
+		if d, err := Check(args); err != nil || d.Supervise {
+			t.Errorf("Check(%v) Supervise = %v (%v), want false", args, d.Supervise, err)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package guard
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Kubeconfig is the merged view of one or more kubeconfig files.
+type Kubeconfig struct {
+	CurrentContext string
+	Clusters       map[string]Cluster
+	Users          map[string]User
+	Contexts       map[string]Context
+
+	// Paths lists the files that were considered, in precedence order.
+	Paths []string
+}
+
+// Cluster is a named cluster entry from a kubeconfig.
+type Cluster struct {
+	Name                     string
+	Server                   string
+	TLSServerName            string
+	CertificateAuthority     string
+	CertificateAuthorityData []byte
+	InsecureSkipTLSVerify    bool
+}
+
+// User is a named user (auth info) entry from a kubeconfig.
+type User struct {
+	Name              string
+	ClientCertificate string
+	ClientKey         string
+	Username          string
+	HasToken          bool
+	HasExec           bool
+}
+
+// Context is a named context entry from a kubeconfig.
+type Context struct {
+	Name      string
+	Cluster   string
+	User      string
+	Namespace string
+}
+
+// kubeconfigFile mirrors the on-disk kubeconfig layout.
+type kubeconfigFile struct {
+	CurrentContext string `yaml:"current-context"`
+	Clusters       []struct {
+		Name    string `yaml:"name"`
+		Cluster struct {
+			Server                   string `yaml:"server"`
+			TLSServerName            string `yaml:"tls-server-name"`
+			CertificateAuthority     string `yaml:"certificate-authority"`
+			CertificateAuthorityData string `yaml:"certificate-authority-data"`
+			InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify"`
+		} `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Users []struct {
+		Name string `yaml:"name"`
+		User struct {
+			ClientCertificate string    `yaml:"client-certificate"`
+			ClientKey         string    `yaml:"client-key"`
+			Username          string    `yaml:"username"`
+			Token             string    `yaml:"token"`
+			TokenFile         string    `yaml:"tokenFile"`
+			Exec              yaml.Node `yaml:"exec"`
+		} `yaml:"user"`
+	} `yaml:"users"`
+	Contexts []struct {
+		Name    string `yaml:"name"`
+		Context struct {
+			Cluster   string `yaml:"cluster"`
+			User      string `yaml:"user"`
+			Namespace string `yaml:"namespace"`
+		} `yaml:"context"`
+	} `yaml:"contexts"`
+}
+
+// KubeconfigPaths returns the kubeconfig files kubectl would read, in
+// precedence order. An explicit --kubeconfig path replaces everything else;
+// otherwise KUBECONFIG is used, falling back to ~/.kube/config.
+func KubeconfigPaths(explicit string) []string {
+	if explicit != "" {
+		return []string{explicit}
+	}
+
+	if env := os.Getenv("KUBECONFIG"); env != "" {
+		var paths []string
+		seen := make(map[string]bool)
+		for _, p := range filepath.SplitList(env) {
+			if p == "" || seen[p] {
+				continue
+			}
+			seen[p] = true
+			paths = append(paths, p)
+		}
+		return paths
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	return []string{filepath.Join(home, ".kube", "config")}
+}
+
+// LoadKubeconfig reads and merges the kubeconfig files kubectl would use.
+// An explicit path must exist; missing files from KUBECONFIG are skipped,
+// as kubectl does.
+func LoadKubeconfig(explicit string) (*Kubeconfig, error) {
+	kc := &Kubeconfig{
+		Clusters: make(map[string]Cluster),
+		Users:    make(map[string]User),
+		Contexts: make(map[string]Context),
+		Paths:    KubeconfigPaths(explicit),
+	}
+
+	for _, path := range kc.Paths {
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) && explicit == "" {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := kc.merge(path, data); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	return kc, nil
+}
+
+// merge folds one file into the config using kubectl's precedence rules:
+// the first file to set current-context or to define a named entry wins.
+func (kc *Kubeconfig) merge(path string, data []byte) error {
+	var f kubeconfigFile
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return err
+	}
+
+	if kc.CurrentContext == "" {
+		kc.CurrentContext = f.CurrentContext
+	}
+
+	dir := filepath.Dir(path)
+	for _, c := range f.Clusters {
+		if _, ok := kc.Clusters[c.Name]; ok || c.Name == "" {
+			continue
+		}
+		caData, err := base64.StdEncoding.DecodeString(c.Cluster.CertificateAuthorityData)
+		if err != nil {
+			return fmt.Errorf("cluster %q: invalid certificate-authority-data: %w", c.Name, err)
+		}
+		kc.Clusters[c.Name] = Cluster{
+			Name:                     c.Name,
+			Server:                   c.Cluster.Server,
+			TLSServerName:            c.Cluster.TLSServerName,
+			CertificateAuthority:     resolvePath(dir, c.Cluster.CertificateAuthority),
+			CertificateAuthorityData: caData,
+			InsecureSkipTLSVerify:    c.Cluster.InsecureSkipTLSVerify,
+		}
+	}
+
+	for _, u := range f.Users {
+		if _, ok := kc.Users[u.Name]; ok || u.Name == "" {
+			continue
+		}
+		kc.Users[u.Name] = User{
+			Name:              u.Name,
+			ClientCertificate: resolvePath(dir, u.User.ClientCertificate),
+			ClientKey:         resolvePath(dir, u.User.ClientKey),
+			Username:          u.User.Username,
+			HasToken:          u.User.Token != "" || u.User.TokenFile != "",
+			HasExec:           !u.User.Exec.IsZero(),
+		}
+	}
+
+	for _, c := range f.Contexts {
+		if _, ok := kc.Contexts[c.Name]; ok || c.Name == "" {
+			continue
+		}
+		kc.Contexts[c.Name] = Context{
+			Name:      c.Name,
+			Cluster:   c.Context.Cluster,
+			User:      c.Context.User,
+			Namespace: c.Context.Namespace,
+		}
+	}
+
+	return nil
+}
+
+// ContextNames returns all context names, sorted as kubectl lists them.
+func (kc *Kubeconfig) ContextNames() []string {
+	names := make([]string, 0, len(kc.Contexts))
+	for name := range kc.Contexts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Target returns the target for a named context, filling in its cluster,
+// user, default namespace, API server and CA fingerprint. An undefined
+// context yields a target with only the name and "default" namespace set.
+func (kc *Kubeconfig) Target(context string) Target {
+	t := Target{Context: context, Namespace: "default"}
+	if c, ok := kc.Contexts[context]; ok {
+		t.Cluster = c.Cluster
+		t.User = c.User
+		if c.Namespace != "" {
+			t.Namespace = c.Namespace
+		}
+	}
+	kc.fillCluster(&t)
+	return t
+}
+
+// fillCluster sets the server and CA fingerprint from t's cluster entry.
+func (kc *Kubeconfig) fillCluster(t *Target) {
+	t.Server, t.CAFingerprint = "", ""
+	if c, ok := kc.Clusters[t.Cluster]; ok {
+		t.Server = c.Server
+		// An unreadable CA file leaves the fingerprint empty; kubectl will
+		// report the problem itself.
+		t.CAFingerprint, _ = c.CAFingerprint()
+	}
+}
+
+// CAFingerprint returns the SHA-256 fingerprint of the cluster's CA
+// certificate, read from certificate-authority-data or certificate-authority.
+// It returns an empty string when the cluster has no CA configured.
+func (c Cluster) CAFingerprint() (string, error) {
+	data := c.CertificateAuthorityData
+	if len(data) == 0 && c.CertificateAuthority != "" {
+		var err error
+		data, err = os.ReadFile(c.CertificateAuthority)
+		if err != nil {
+			return "", err
+		}
+	}
+	return Fingerprint(data), nil
+}
+
+// Fingerprint returns the hex SHA-256 of the first PEM certificate in data
+// (the same value as `openssl x509 -fingerprint -sha256`), or of the raw
+// bytes when data is not PEM encoded.
+func Fingerprint(data []byte) string {
+	if len(data) == 0 {
+		return ""
+	}
+	if block, _ := pem.Decode(data); block != nil {
+		data = block.Bytes
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// resolvePath makes a file reference relative to the kubeconfig that contains it.
+func resolvePath(dir, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
@@ -0,0 +1,214 @@
+package guard
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testKubeconfigA = `apiVersion: v1
+kind: Config
+current-context: prod
+clusters:
+- name: prod-cluster
+  cluster:
+    server: https://prod.example.com:6443
+    certificate-authority: certs/ca.crt
+- name: shared
+  cluster:
+    server: https://a.example.com
+contexts:
+- name: prod
+  context:
+    cluster: prod-cluster
+    user: admin
+    namespace: payments
+- name: minikube
+  context:
+    cluster: minikube
+    user: minikube
+users:
+- name: admin
+  user:
+    token: secret
+`
+
+const testKubeconfigB = `apiVersion: v1
+kind: Config
+current-context: staging
+clusters:
+- name: shared
+  cluster:
+    server: https://b.example.com
+    certificate-authority-data: aGVsbG8=
+contexts:
+- name: prod
+  context:
+    cluster: other
+- name: staging
+  context:
+    cluster: shared
+users:
+- name: dev
+  user:
+    exec:
+      command: aws
+`
+
+// writeKubeconfigs writes the given kubeconfig contents to a temp directory
+// and points KUBECONFIG at them in order.
+func writeKubeconfigs(t *testing.T, contents ...string) []string {
+	t.Helper()
+	dir := t.TempDir()
+	paths := make([]string, len(contents))
+	for i, c := range contents {
+		paths[i] = filepath.Join(dir, "config-"+string(rune('a'+i)))
+		if err := os.WriteFile(paths[i], []byte(c), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("KUBECONFIG", strings.Join(paths, string(os.PathListSeparator)))
+	return paths
+}
+
+func TestLoadKubeconfigMerge(t *testing.T) {
+	paths := writeKubeconfigs(t, testKubeconfigA, testKubeconfigB)
+
+	kc, err := LoadKubeconfig("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first file to set current-context wins.
+	if kc.CurrentContext != "prod" {
+		t.Errorf("CurrentContext = %q, want %q", kc.CurrentContext, "prod")
+	}
+
+	// The first file to define a name wins; later definitions are ignored.
+	if got := kc.Contexts["prod"].Cluster; got != "prod-cluster" {
+		t.Errorf("prod cluster = %q, want %q", got, "prod-cluster")
+	}
+	if got := kc.Clusters["shared"].Server; got != "https://a.example.com" {
+		t.Errorf("shared server = %q, want %q", got, "https://a.example.com")
+	}
+
+	// Entries only present in later files are still merged in.
+	if _, ok := kc.Contexts["staging"]; !ok {
+		t.Error("staging context missing from merged config")
+	}
+	if !kc.Users["dev"].HasExec {
+		t.Error("dev user should have exec credentials")
+	}
+	if !kc.Users["admin"].HasToken {
+		t.Error("admin user should have a token")
+	}
+
+	// Relative file references resolve against the file that contains them.
+	want := filepath.Join(filepath.Dir(paths[0]), "certs", "ca.crt")
+	if got := kc.Clusters["prod-cluster"].CertificateAuthority; got != want {
+		t.Errorf("CertificateAuthority = %q, want %q", got, want)
+	}
+
+	if got := strings.Join(kc.ContextNames(), ","); got != "minikube,prod,staging" {
+		t.Errorf("ContextNames() = %q", got)
+	}
+}
+
+func TestLoadKubeconfigCAData(t *testing.T) {
+	writeKubeconfigs(t, testKubeconfigB)
+
+	kc, err := LoadKubeconfig("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(kc.Clusters["shared"].CertificateAuthorityData); got != "hello" {
+		t.Errorf("CertificateAuthorityData = %q, want %q", got, "hello")
+	}
+}
+
+func TestLoadKubeconfigMissingFiles(t *testing.T) {
+	dir := t.TempDir()
+	missing := filepath.Join(dir, "missing")
+
+	// Missing files listed in KUBECONFIG are skipped.
+	t.Setenv("KUBECONFIG", missing)
+	kc, err := LoadKubeconfig("")
+	if err != nil {
+		t.Fatalf("LoadKubeconfig() error = %v, want nil", err)
+	}
+	if len(kc.Contexts) != 0 {
+		t.Errorf("expected no contexts, got %d", len(kc.Contexts))
+	}
+
+	// An explicit --kubeconfig must exist.
+	if _, err := LoadKubeconfig(missing); err == nil {
+		t.Error("LoadKubeconfig(explicit missing) error = nil, want error")
+	}
+}
+
+func TestKubeconfigPaths(t *testing.T) {
+	sep := string(os.PathListSeparator)
+	t.Setenv("KUBECONFIG", "a"+sep+sep+"b"+sep+"a")
+
+	if got := strings.Join(KubeconfigPaths(""), ","); got != "a,b" {
+		t.Errorf("KubeconfigPaths() = %q, want %q", got, "a,b")
+	}
+	if got := strings.Join(KubeconfigPaths("explicit"), ","); got != "explicit" {
+		t.Errorf("KubeconfigPaths(explicit) = %q, want %q", got, "explicit")
+	}
+
+	t.Setenv("KUBECONFIG", "")
+	t.Setenv("HOME", "/home/test")
+	if got := KubeconfigPaths(""); len(got) != 1 || got[0] != filepath.Join("/home/test", ".kube", "config") {
+		t.Errorf("KubeconfigPaths() = %v, want default path", got)
+	}
+}
+
+const testCACert = `-----BEGIN CERTIFICATE-----
+aGVsbG8gd29ybGQ=
+-----END CERTIFICATE-----
+`
+
+func TestFingerprint(t *testing.T) {
+	// sha256("hello world"), the DER bytes inside the PEM block.
+	want := "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"
+	if got := Fingerprint([]byte(testCACert)); got != want {
+		t.Errorf("Fingerprint(PEM) = %q, want %q", got, want)
+	}
+	if got := Fingerprint([]byte("hello world")); got != want {
+		t.Errorf("Fingerprint(raw) = %q, want %q", got, want)
+	}
+	if got := Fingerprint(nil); got != "" {
+		t.Errorf("Fingerprint(nil) = %q, want empty", got)
+	}
+}
+
+func TestKubeconfigTarget(t *testing.T) {
+	paths := writeKubeconfigs(t, testKubeconfigA)
+	caPath := filepath.Join(filepath.Dir(paths[0]), "certs", "ca.crt")
+	if err := os.MkdirAll(filepath.Dir(caPath), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(caPath, []byte(testCACert), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	kc, err := LoadKubeconfig("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	target := kc.Target("prod")
+	if target.Server != "https://prod.example.com:6443" {
+		t.Errorf("Server = %q", target.Server)
+	}
+	if target.CAFingerprint != Fingerprint([]byte(testCACert)) {
+		t.Errorf("CAFingerprint = %q", target.CAFingerprint)
+	}
+
+	undefined := kc.Target("nope")
+	if undefined.Context != "nope" || undefined.Cluster != "" || undefined.Server != "" {
+		t.Errorf("Target(undefined) = %+v", undefined)
+	}
+}
@@ -0,0 +1,404 @@
+package guard
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// maxListedManifests limits how many objects a prompt lists.
+const maxListedManifests = 25
+
+// manifestExtensions are the file types kubectl reads from a -f directory.
+var manifestExtensions = []string{".json", ".yaml", ".yml"}
+
+// kustomizationFiles are the names kustomize looks for in a directory.
+var kustomizationFiles = []string{"kustomization.yaml", "kustomization.yml", "Kustomization"}
+
+// Manifest is an object read from a -f or -k source.
+type Manifest struct {
+	Kind      string
+	Name      string
+	Namespace string
+	// Source is the file the object was read from.
+	Source string
+}
+
+// Manifests are the objects named by a command's -f and -k arguments.
+type Manifests struct {
+	Objects []Manifest
+	// Warnings note sources that could not be inspected, such as URLs.
+	Warnings []string
+	// Uninspected is set when a source could not be read, such as stdin or
+	// a URL, so Objects may be missing some of what kubectl will send.
+	Uninspected bool
+}
+
+// manifestObject is the part of a Kubernetes object the guard reads.
+type manifestObject struct {
+	Kind     string `yaml:"kind"`
+	Metadata struct {
+		Name         string `yaml:"name"`
+		GenerateName string `yaml:"generateName"`
+		Namespace    string `yaml:"namespace"`
+	} `yaml:"metadata"`
+	Items []manifestObject `yaml:"items"`
+}
+
+// kustomization is the part of a kustomization file the guard reads.
+// Patches, generators and transformers are not applied.
+type kustomization struct {
+	Resources  []string `yaml:"resources"`
+	Bases      []string `yaml:"bases"`
+	Components []string `yaml:"components"`
+	Namespace  string   `yaml:"namespace"`
+	NamePrefix string   `yaml:"namePrefix"`
+	NameSuffix string   `yaml:"nameSuffix"`
+
+	Patches               []any `yaml:"patches"`
+	PatchesStrategicMerge []any `yaml:"patchesStrategicMerge"`
+	PatchesJSON6902       []any `yaml:"patchesJson6902"`
+	ConfigMapGenerator    []any `yaml:"configMapGenerator"`
+	SecretGenerator       []any `yaml:"secretGenerator"`
+	Generators            []any `yaml:"generators"`
+	Transformers          []any `yaml:"transformers"`
+}
+
+// Manifests reads the objects named by the command's -f and -k arguments.
+// stdin is the buffered input for "-f -"; when nil, stdin is not inspected.
+// Problems reading a source are reported as warnings rather than errors:
+// kubectl will report them properly when it runs.
+func (p ParsedCommand) Manifests(stdin []byte) Manifests {
+	var m Manifests
+	recursive := p.Bool("recursive")
+	for _, f := range p.Flags["filename"] {
+		if f == "-" && stdin != nil {
+			m.readStdin(stdin)
+			continue
+		}
+		m.readFilename(f, recursive)
+	}
+	for _, k := range p.Flags["kustomize"] {
+		m.readKustomization(k, map[string]bool{})
+	}
+	return m
+}
+
+// readFilename reads a -f argument: a file, a directory or a URL.
+func (m *Manifests) readFilename(f string, recursive bool) {
+	switch {
+	case f == "-":
+		m.skip("manifests from stdin were not inspected")
+		return
+	case isURL(f):
+		m.skip(fmt.Sprintf("%s is a URL; its contents were not inspected", f))
+		return
+	}
+
+	info, err := os.Stat(f)
+	if err != nil {
+		m.skip(fmt.Sprintf("could not read %s: %v", f, err))
+		return
+	}
+	if !info.IsDir() {
+		m.readFile(f)
+		return
+	}
+
+	err = filepath.WalkDir(f, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != f && !recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if hasManifestExtension(path) {
+			m.readFile(path)
+		}
+		return nil
+	})
+	if err != nil {
+		m.skip(fmt.Sprintf("could not read %s: %v", f, err))
+	}
+}
+
+// readFile decodes every document in a YAML or JSON file.
+func (m *Manifests) readFile(path string) {
+	objects, err := readManifestFile(path)
+	m.Objects = append(m.Objects, objects...)
+	if err != nil {
+		m.skip(fmt.Sprintf("could not parse %s: %v", path, err))
+	}
+}
+
+// readStdin decodes manifests buffered from stdin.
+func (m *Manifests) readStdin(data []byte) {
+	objects, err := decodeManifests(bytes.NewReader(data), "stdin")
+	m.Objects = append(m.Objects, objects...)
+	if err != nil {
+		m.skip(fmt.Sprintf("could not parse stdin: %v", err))
+	}
+}
+
+// readKustomization reads the resources of a kustomization directory,
+// following nested bases and components. visited guards against cycles.
+func (m *Manifests) readKustomization(dir string, visited map[string]bool) {
+	if isURL(dir) {
+		m.skip(fmt.Sprintf("%s is a remote kustomization; its contents were not inspected", dir))
+		return
+	}
+	if abs, err := filepath.Abs(dir); err == nil {
+		if visited[abs] {
+			return
+		}
+		visited[abs] = true
+	}
+
+	k, path, err := loadKustomization(dir)
+	if err != nil {
+		m.skip(fmt.Sprintf("could not read kustomization in %s: %v", dir, err))
+		return
+	}
+	if k.transforms() {
+		m.warn(fmt.Sprintf("%s uses patches or generators; objects are listed before they are applied", path))
+	}
+
+	var sub Manifests
+	for _, res := range append(append(k.Resources, k.Bases...), k.Components...) {
+		if isURL(res) || strings.Contains(res, "?ref=") {
+			sub.skip(fmt.Sprintf("%s is a remote resource; its contents were not inspected", res))
+			continue
+		}
+		res = filepath.Join(dir, res)
+		info, err := os.Stat(res)
+		if err != nil {
+			sub.skip(fmt.Sprintf("could not read %s: %v", res, err))
+			continue
+		}
+		if info.IsDir() {
+			sub.readKustomization(res, visited)
+		} else {
+			sub.readFile(res)
+		}
+	}
+
+	for _, obj := range sub.Objects {
+		m.Objects = append(m.Objects, k.transform(obj))
+	}
+	m.Warnings = append(m.Warnings, sub.Warnings...)
+	m.Uninspected = m.Uninspected || sub.Uninspected
+}
+
+func (m *Manifests) warn(message string) {
+	m.Warnings = append(m.Warnings, message)
+}
+
+// skip warns about a source that could not be read.
+func (m *Manifests) skip(message string) {
+	m.warn(message)
+	m.Uninspected = true
+}
+
+// loadKustomization reads the kustomization file in dir.
+func loadKustomization(dir string) (kustomization, string, error) {
+	for _, name := range kustomizationFiles {
+		path := filepath.Join(dir, name)
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return kustomization{}, path, err
+		}
+		var k kustomization
+		if err := yaml.Unmarshal(data, &k); err != nil {
+			return kustomization{}, path, err
+		}
+		return k, path, nil
+	}
+	return kustomization{}, "", errors.New("no kustomization file found")
+}
+
+// transforms reports whether the kustomization changes objects in ways the
+// guard doesn't model.
+func (k kustomization) transforms() bool {
+	return len(k.Patches) > 0 || len(k.PatchesStrategicMerge) > 0 || len(k.PatchesJSON6902) > 0 ||
+		len(k.ConfigMapGenerator) > 0 || len(k.SecretGenerator) > 0 ||
+		len(k.Generators) > 0 || len(k.Transformers) > 0
+}
+
+// transform applies the kustomization's namespace and name affixes.
+func (k kustomization) transform(obj Manifest) Manifest {
+	kind := kindOf(obj.Kind)
+	if k.Namespace != "" && !kinds[kind].clusterScoped {
+		obj.Namespace = k.Namespace
+	}
+	if kind != "namespaces" && kind != "customresourcedefinitions" {
+		obj.Name = k.NamePrefix + obj.Name + k.NameSuffix
+	}
+	return obj
+}
+
+// readManifestFile decodes a multi-document YAML or JSON file. JSON is
+// valid YAML, so one decoder handles both.
+func readManifestFile(path string) ([]Manifest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return decodeManifests(f, path)
+}
+
+// decodeManifests decodes every document in r, expanding List objects.
+func decodeManifests(r io.Reader, source string) ([]Manifest, error) {
+	var out []Manifest
+	dec := yaml.NewDecoder(r)
+	for {
+		var obj manifestObject
+		err := dec.Decode(&obj)
+		if errors.Is(err, io.EOF) {
+			return out, nil
+		}
+		if err != nil {
+			return out, err
+		}
+		out = append(out, obj.manifests(source)...)
+	}
+}
+
+// manifests flattens an object, or the items of a List, into manifests.
+// Empty documents yield nothing.
+func (o manifestObject) manifests(source string) []Manifest {
+	if strings.HasSuffix(o.Kind, "List") && o.Items != nil {
+		var out []Manifest
+		for _, item := range o.Items {
+			out = append(out, item.manifests(source)...)
+		}
+		return out
+	}
+	if o.Kind == "" {
+		return nil
+	}
+
+	name := o.Metadata.Name
+	if name == "" && o.Metadata.GenerateName != "" {
+		name = o.Metadata.GenerateName + "<generated>"
+	}
+	return []Manifest{{
+		Kind:      o.Kind,
+		Name:      name,
+		Namespace: o.Metadata.Namespace,
+		Source:    source,
+	}}
+}
+
+// ClusterScoped reports whether the object's kind is not namespaced.
+func (m Manifest) ClusterScoped() bool {
+	return kinds[kindOf(m.Kind)].clusterScoped
+}
+
+// EffectiveNamespace returns the namespace the object will land in when
+// the command runs against t, or "" for cluster-scoped kinds.
+func (m Manifest) EffectiveNamespace(t Target) string {
+	switch {
+	case m.ClusterScoped():
+		return ""
+	case m.Namespace != "":
+		return m.Namespace
+	default:
+		return t.Namespace
+	}
+}
+
+// NamespaceMismatch reports whether the object names a namespace other
+// than the one the command resolved to.
+func (m Manifest) NamespaceMismatch(t Target) bool {
+	return !m.ClusterScoped() && !t.AllNamespaces && m.Namespace != "" && m.Namespace != t.Namespace
+}
+
+// String returns e.g. "Deployment web in namespace payments".
+func (m Manifest) String() string {
+	name := m.Name
+	if name == "" {
+		name = "<unnamed>"
+	}
+	if m.ClusterScoped() {
+		return fmt.Sprintf("%s %s (cluster-scoped)", m.Kind, name)
+	}
+	if m.Namespace == "" {
+		return fmt.Sprintf("%s %s", m.Kind, name)
+	}
+	return fmt.Sprintf("%s %s in namespace %s", m.Kind, name, m.Namespace)
+}
+
+// Namespaces returns the namespaces the objects name: the namespace each
+// is in and the name of each Namespace object, without duplicates.
+func (m Manifests) Namespaces() []string {
+	var out []string
+	for _, obj := range m.Objects {
+		ns := obj.Namespace
+		switch {
+		case kindOf(obj.Kind) == "namespaces":
+			ns = obj.Name
+		case obj.ClusterScoped():
+			continue
+		}
+		if ns != "" && !slices.Contains(out, ns) {
+			out = append(out, ns)
+		}
+	}
+	return out
+}
+
+// Describe lists the objects and warnings for a prompt, one per line, with
+// each object's effective namespace under t. Objects whose namespace
+// differs from the resolved one are flagged.
+func (m Manifests) Describe(t Target) []string {
+	var lines []string
+	for i, obj := range m.Objects {
+		if i == maxListedManifests {
+			lines = append(lines, fmt.Sprintf("… and %d more", len(m.Objects)-i))
+			break
+		}
+		if obj.Namespace == "" && !obj.ClusterScoped() {
+			obj.Namespace = obj.EffectiveNamespace(t)
+		}
+		line := obj.String()
+		if obj.NamespaceMismatch(t) {
+			line = fmt.Sprintf("⚠️  %s (not %s)", line, t.Namespace)
+		}
+		lines = append(lines, line)
+	}
+	for _, w := range m.Warnings {
+		lines = append(lines, "⚠️  "+w)
+	}
+	return lines
+}
+
+// kindOf returns the canonical resource name for an object kind, e.g.
+// "namespaces" for "Namespace".
+func kindOf(kind string) string {
+	if k := normalizeKinds([]string{kind}); len(k) > 0 {
+		return k[0]
+	}
+	return ""
+}
+
+func hasManifestExtension(path string) bool {
+	return slices.Contains(manifestExtensions, strings.ToLower(filepath.Ext(path)))
+}
+
+func isURL(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
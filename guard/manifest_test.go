@@ -0,0 +1,162 @@
+package guard
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const testManifests = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+---
+# comment-only document
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web-config
+  namespace: kube-system
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: payments
+`
+
+func writeManifest(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// objectNames returns "Kind/name" for each manifest.
+func objectNames(m Manifests) []string {
+	var names []string
+	for _, obj := range m.Objects {
+		names = append(names, obj.Kind+"/"+obj.Name)
+	}
+	return names
+}
+
+func TestManifestsFromFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.yaml")
+	writeManifest(t, path, testManifests)
+
+	m := ParseCommand([]string{"apply", "-f", path}).Manifests(nil)
+	want := []string{"Deployment/web", "ConfigMap/web-config", "Namespace/payments"}
+	if got := objectNames(m); !slices.Equal(got, want) {
+		t.Errorf("objects = %v, want %v", got, want)
+	}
+	if len(m.Warnings) != 0 {
+		t.Errorf("warnings = %v, want none", m.Warnings)
+	}
+}
+
+func TestManifestsJSONAndLists(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "list.json")
+	writeManifest(t, path, `{
+	"apiVersion": "v1",
+	"kind": "List",
+	"items": [
+		{"kind": "Service", "metadata": {"name": "web"}},
+		{"kind": "Job", "metadata": {"generateName": "migrate-"}}
+	]
+}`)
+
+	m := ParseCommand([]string{"create", "--filename=" + path}).Manifests(nil)
+	want := []string{"Service/web", "Job/migrate-<generated>"}
+	if got := objectNames(m); !slices.Equal(got, want) {
+		t.Errorf("objects = %v, want %v", got, want)
+	}
+}
+
+func TestManifestsFromDirectory(t *testing.T) {
+	dir := t.TempDir()
+	writeManifest(t, filepath.Join(dir, "a.yaml"), "kind: Service\nmetadata: {name: a}\n")
+	writeManifest(t, filepath.Join(dir, "README.md"), "kind: Service\nmetadata: {name: readme}\n")
+	writeManifest(t, filepath.Join(dir, "sub", "b.yml"), "kind: Service\nmetadata: {name: b}\n")
+
+	if got := objectNames(ParseCommand([]string{"apply", "-f", dir}).Manifests(nil)); !slices.Equal(got, []string{"Service/a"}) {
+		t.Errorf("objects without -R = %v, want [Service/a]", got)
+	}
+	if got := objectNames(ParseCommand([]string{"apply", "-f", dir, "-R"}).Manifests(nil)); !slices.Equal(got, []string{"Service/a", "Service/b"}) {
+		t.Errorf("objects with -R = %v, want [Service/a Service/b]", got)
+	}
+}
+
+func TestManifestsWarnings(t *testing.T) {
+	dir := t.TempDir()
+	bad := filepath.Join(dir, "bad.yaml")
+	writeManifest(t, bad, "kind: [unterminated\n")
+
+	m := ParseCommand([]string{"apply", "-f", "https://example.com/app.yaml", "-f", bad, "-f", filepath.Join(dir, "missing.yaml")}).Manifests(nil)
+	if len(m.Objects) != 0 {
+		t.Errorf("objects = %v, want none", objectNames(m))
+	}
+	if len(m.Warnings) != 3 || !strings.Contains(m.Warnings[0], "is a URL") {
+		t.Errorf("warnings = %q, want URL, parse and read warnings", m.Warnings)
+	}
+}
+
+func TestManifestsFromKustomization(t *testing.T) {
+	dir := t.TempDir()
+	writeManifest(t, filepath.Join(dir, "base", "kustomization.yaml"), "resources:\n  - app.yaml\n")
+	writeManifest(t, filepath.Join(dir, "base", "app.yaml"), testManifests)
+	writeManifest(t, filepath.Join(dir, "prod", "kustomization.yaml"), `namespace: payments
+namePrefix: prod-
+resources:
+  - ../base
+  - github.com/example/repo//deploy?ref=v1
+patches:
+  - path: replicas.yaml
+`)
+
+	m := ParseCommand([]string{"apply", "-k", filepath.Join(dir, "prod")}).Manifests(nil)
+	var got []string
+	for _, obj := range m.Objects {
+		got = append(got, obj.String())
+	}
+	want := []string{
+		"Deployment prod-web in namespace payments",
+		"ConfigMap prod-web-config in namespace payments",
+		"Namespace payments (cluster-scoped)",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("objects = %q, want %q", got, want)
+	}
+	if len(m.Warnings) != 2 {
+		t.Errorf("warnings = %q, want patches and remote resource warnings", m.Warnings)
+	}
+}
+
+func TestManifestsDescribe(t *testing.T) {
+	m := Manifests{
+		Objects: []Manifest{
+			{Kind: "Deployment", Name: "web"},
+			{Kind: "ConfigMap", Name: "cfg", Namespace: "kube-system"},
+			{Kind: "ClusterRole", Name: "reader"},
+		},
+		Warnings: []string{"https://x is a URL; its contents were not inspected"},
+	}
+
+	got := m.Describe(Target{Context: "prod", Namespace: "payments"})
+	want := []string{
+		"Deployment web in namespace payments",
+		"⚠️  ConfigMap cfg in namespace kube-system (not payments)",
+		"ClusterRole reader (cluster-scoped)",
+		"⚠️  https://x is a URL; its contents were not inspected",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Describe() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,71 @@
+package guard
+
+import (
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/cameronlockhart/kubectl-guard/config"
+)
+
+// Environment variables that approve commands without a prompt.
+const (
+	// EnvAssumeYes, set to 1, true or yes, answers every confirmation
+	// prompt with yes. Blocked commands stay blocked.
+	EnvAssumeYes = "KUBECTL_GUARD_ASSUME_YES"
+	// EnvApprovalToken carries the token checked by the token
+	// non-interactive policy.
+	EnvApprovalToken = "KUBECTL_GUARD_APPROVAL_TOKEN"
+)
+
+var (
+	// ErrNoPolicy means no non-interactive policy is configured, so a
+	// command that needs confirmation cannot run without a terminal.
+	ErrNoPolicy = errors.New("no non-interactive policy configured")
+	// ErrDeniedByPolicy means the non-interactive policy refused the command.
+	ErrDeniedByPolicy = errors.New("denied by the non-interactive policy")
+)
+
+// Approval names what approved a command in place of a confirmation prompt.
+type Approval string
+
+// Approvals recorded for commands run without a prompt.
+const (
+	ApprovedAssumeYes Approval = EnvAssumeYes
+	ApprovedGuardYes  Approval = "--guard-yes"
+	ApprovedByPolicy  Approval = "non_interactive: allow"
+	ApprovedByToken   Approval = "approval token"
+)
+
+// AssumeYes reports whether KUBECTL_GUARD_ASSUME_YES is set to a true value.
+func AssumeYes() bool {
+	switch strings.ToLower(os.Getenv(EnvAssumeYes)) {
+	case "1", "true", "yes":
+		return true
+	}
+	return false
+}
+
+// ApproveNonInteractive applies the configured non-interactive policy to a
+// command that needs confirmation when there is no terminal to ask on. It
+// returns ErrNoPolicy when none is configured and ErrDeniedByPolicy when
+// the policy refuses the command.
+func ApproveNonInteractive() (Approval, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return "", err
+	}
+
+	switch cfg.NonInteractive {
+	case config.NonInteractiveAllow:
+		return ApprovedByPolicy, nil
+	case config.NonInteractiveToken:
+		if cfg.TokenMatches(os.Getenv(EnvApprovalToken)) {
+			return ApprovedByToken, nil
+		}
+		return "", ErrDeniedByPolicy
+	case config.NonInteractiveDeny:
+		return "", ErrDeniedByPolicy
+	}
+	return "", ErrNoPolicy
+}
@@ -0,0 +1,65 @@
+package guard
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cameronlockhart/kubectl-guard/config"
+)
+
+func TestAssumeYes(t *testing.T) {
+	for value, want := range map[string]bool{
+		"":      false,
+		"0":     false,
+		"no":    false,
+		"1":     true,
+		"true":  true,
+		"YES":   true,
+		"maybe": false,
+	} {
+		t.Setenv(EnvAssumeYes, value)
+		if got := AssumeYes(); got != want {
+			t.Errorf("AssumeYes() with %s=%q = %v, want %v", EnvAssumeYes, value, got, want)
+		}
+	}
+}
+
+func TestApproveNonInteractive(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   config.NonInteractivePolicy
+		token    string
+		approval Approval
+		err      error
+	}{
+		{name: "unset", err: ErrNoPolicy},
+		{name: "deny", policy: config.NonInteractiveDeny, err: ErrDeniedByPolicy},
+		{name: "allow", policy: config.NonInteractiveAllow, approval: ApprovedByPolicy},
+		{name: "token", policy: config.NonInteractiveToken, token: "s3cret", approval: ApprovedByToken},
+		{name: "wrong token", policy: config.NonInteractiveToken, token: "guess", err: ErrDeniedByPolicy},
+		{name: "missing token", policy: config.NonInteractiveToken, err: ErrDeniedByPolicy},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HOME", t.TempDir())
+			t.Setenv(EnvApprovalToken, tt.token)
+			cfg := &config.Config{
+				ProtectedContexts:   []string{"prod"},
+				NonInteractive:      tt.policy,
+				ApprovalTokenSHA256: config.HashToken("s3cret"),
+			}
+			if err := config.Save(cfg); err != nil {
+				t.Fatal(err)
+			}
+
+			approval, err := ApproveNonInteractive()
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("ApproveNonInteractive() error = %v, want %v", err, tt.err)
+			}
+			if approval != tt.approval {
+				t.Errorf("ApproveNonInteractive() = %q, want %q", approval, tt.approval)
+			}
+		})
+	}
+}
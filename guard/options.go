@@ -0,0 +1,89 @@
+package guard
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// optionPrefix marks the flags reserved for kubectl-guard itself. They are
+// removed from the command line and never reach kubectl.
+const optionPrefix = "--guard-"
+
+// Options are the --guard-* flags given inline with a kubectl command.
+type Options struct {
+	// Yes answers the confirmation prompt, like KUBECTL_GUARD_ASSUME_YES
+	// for a single command (--guard-yes). Blocked commands stay blocked.
+	Yes bool
+	// Reason says why the command is being run (--guard-reason).
+	Reason string
+	// Explain prints the decision without running kubectl
+	// (--guard-explain, or its alias --guard-dry-run).
+	Explain bool
+	// ExpectContext refuses the command unless it resolves to a context
+	// matching this glob (--guard-expect-context).
+	ExpectContext string
+}
+
+// ParseOptions removes the --guard-* flags from args and returns them with
+// the remaining arguments. Flags after "--" belong to the command kubectl
+// runs and are left alone. Unknown --guard-* flags are an error rather
+// than being passed to kubectl.
+func ParseOptions(args []string) (Options, []string, error) {
+	var opts Options
+	rest := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+		if !strings.HasPrefix(arg, optionPrefix) {
+			rest = append(rest, arg)
+			continue
+		}
+
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(arg, optionPrefix), "=")
+		switch name {
+		case "yes", "explain", "dry-run":
+			b := true
+			if hasValue {
+				var err error
+				if b, err = strconv.ParseBool(value); err != nil {
+					return Options{}, nil, fmt.Errorf("invalid value %q for %s%s", value, optionPrefix, name)
+				}
+			}
+			if name == "yes" {
+				opts.Yes = b
+			} else {
+				opts.Explain = b
+			}
+		case "reason", "expect-context":
+			if !hasValue {
+				if i+1 == len(args) {
+					return Options{}, nil, fmt.Errorf("%s%s requires a value", optionPrefix, name)
+				}
+				i++
+				value = args[i]
+			}
+			if name == "reason" {
+				opts.Reason = value
+			} else {
+				opts.ExpectContext = value
+			}
+		default:
+			return Options{}, nil, fmt.Errorf("unknown flag %s%s", optionPrefix, name)
+		}
+	}
+	return opts, rest, nil
+}
+
+// ContextExpected reports whether t satisfies --guard-expect-context.
+func (o Options) ContextExpected(t Target) bool {
+	if o.ExpectContext == "" {
+		return true
+	}
+	matched, _ := filepath.Match(o.ExpectContext, t.Context)
+	return matched
+}
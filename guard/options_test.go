@@ -0,0 +1,92 @@
+package guard
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		opts Options
+		rest []string
+	}{
+		{
+			name: "no options",
+			args: []string{"delete", "pod", "x"},
+			rest: []string{"delete", "pod", "x"},
+		},
+		{
+			name: "yes and reason",
+			args: []string{"delete", "pod", "x", "--guard-yes", "--guard-reason", "INC-123"},
+			opts: Options{Yes: true, Reason: "INC-123"},
+			rest: []string{"delete", "pod", "x"},
+		},
+		{
+			name: "equals form",
+			args: []string{"--guard-reason=rollback web", "apply", "-f", "app.yaml", "--guard-expect-context=prod-*"},
+			opts: Options{Reason: "rollback web", ExpectContext: "prod-*"},
+			rest: []string{"apply", "-f", "app.yaml"},
+		},
+		{
+			name: "explain and dry-run alias",
+			args: []string{"delete", "ns", "x", "--guard-dry-run"},
+			opts: Options{Explain: true},
+			rest: []string{"delete", "ns", "x"},
+		},
+		{
+			name: "explicit booleans",
+			args: []string{"delete", "pod", "x", "--guard-yes=false", "--guard-explain=true"},
+			opts: Options{Explain: true},
+			rest: []string{"delete", "pod", "x"},
+		},
+		{
+			name: "stops at double dash",
+			args: []string{"exec", "web", "--guard-yes", "--", "echo", "--guard-reason", "x"},
+			opts: Options{Yes: true},
+			rest: []string{"exec", "web", "--", "echo", "--guard-reason", "x"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, rest, err := ParseOptions(tt.args)
+			if err != nil {
+				t.Fatalf("ParseOptions(%q) error = %v", tt.args, err)
+			}
+			if opts != tt.opts {
+				t.Errorf("ParseOptions(%q) options = %+v, want %+v", tt.args, opts, tt.opts)
+			}
+			if !slices.Equal(rest, tt.rest) {
+				t.Errorf("ParseOptions(%q) args = %q, want %q", tt.args, rest, tt.rest)
+			}
+		})
+	}
+}
+
+func TestParseOptionsErrors(t *testing.T) {
+	for _, args := range [][]string{
+		{"delete", "pod", "x", "--guard-force"},
+		{"delete", "pod", "x", "--guard-reason"},
+		{"delete", "pod", "x", "--guard-yes=maybe"},
+	} {
+		if _, _, err := ParseOptions(args); err == nil {
+			t.Errorf("ParseOptions(%q) error = nil, want error", args)
+		}
+	}
+}
+
+func TestContextExpected(t *testing.T) {
+	target := Target{Context: "prod-eu"}
+	for pattern, want := range map[string]bool{
+		"":        true,
+		"prod-eu": true,
+		"prod-*":  true,
+		"staging": false,
+	} {
+		if got := (Options{ExpectContext: pattern}).ContextExpected(target); got != want {
+			t.Errorf("ContextExpected(%q) = %v, want %v", pattern, got, want)
+		}
+	}
+}
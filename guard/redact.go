@@ -0,0 +1,185 @@
+package guard
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/cameronlockhart/kubectl-guard/config"
+)
+
+// Redacted replaces a secret in displayed and recorded arguments.
+const Redacted = "REDACTED"
+
+// secretKind says how much of a secret flag's value to hide.
+type secretKind int
+
+const (
+	// secretValue hides the whole value.
+	secretValue secretKind = iota
+	// secretKeyValue hides the value of a key=value pair but keeps the
+	// key, so "--from-literal=password=s3cret" still says which key was set.
+	secretKeyValue
+)
+
+func (k secretKind) hide(value string) string {
+	if k == secretKeyValue {
+		if key, _, ok := strings.Cut(value, "="); ok {
+			return key + "=" + Redacted
+		}
+	}
+	return Redacted
+}
+
+// secretFlags are kubectl's flags that carry credentials or secret data,
+// by long name.
+var secretFlags = map[string]secretKind{
+	// Credentials for the API server.
+	"token":      secretValue,
+	"password":   secretValue,
+	"client-key": secretValue,
+	// config set-credentials.
+	"auth-provider-arg": secretKeyValue,
+	"exec-env":          secretKeyValue,
+	// create secret.
+	"from-literal":    secretKeyValue,
+	"from-env-file":   secretValue,
+	"docker-password": secretValue,
+}
+
+// Redactor hides secrets in kubectl arguments before they are shown or
+// recorded: the values of credential flags, secret data given inline, and
+// anything matching the configured patterns. A nil Redactor knows only the
+// built-in flags.
+type Redactor struct {
+	flags    map[string]secretKind
+	patterns []*regexp.Regexp
+}
+
+// NewRedactor returns a Redactor for the built-in secret flags plus the
+// configured ones.
+func NewRedactor(cfg config.RedactConfig) (*Redactor, error) {
+	r := &Redactor{flags: map[string]secretKind{}}
+	for _, flag := range cfg.Flags {
+		r.flags[strings.TrimPrefix(flag, "--")] = secretValue
+	}
+	for _, pattern := range cfg.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+// secret reports whether a long flag's value is secret, and how to hide it.
+func (r *Redactor) secret(name string) (secretKind, bool) {
+	if kind, ok := secretFlags[name]; ok {
+		return kind, true
+	}
+	if r == nil {
+		return 0, false
+	}
+	kind, ok := r.flags[name]
+	return kind, ok
+}
+
+// Args returns a copy of args with secrets hidden. It walks the arguments
+// with kubectl's flag grammar, so "--token abc" hides abc but "get token"
+// hides nothing. Arguments after "--" (such as the command for exec) have
+// secret flags and patterns hidden too.
+func (r *Redactor) Args(args []string) []string {
+	out := slices.Clone(args)
+	var (
+		command     string
+		positionals []int
+		passthrough bool
+	)
+	for i := 0; i < len(out); i++ {
+		arg := out[i]
+		switch {
+		case arg == "--":
+			passthrough = true
+
+		case strings.HasPrefix(arg, "--"):
+			name, value, hasValue := strings.Cut(arg[2:], "=")
+			kind, secret := r.secret(name)
+			if hasValue {
+				if secret {
+					out[i] = "--" + name + "=" + kind.hide(value)
+				}
+				continue
+			}
+			spec, known := lookupFlag(name, false, command)
+			if passthrough {
+				known = false
+			}
+			if (secret || (known && spec.value)) && i+1 < len(out) {
+				i++
+				if secret {
+					out[i] = kind.hide(out[i])
+				}
+			}
+
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			if passthrough {
+				continue
+			}
+			// Skip the value of a short flag the way the parser does; no
+			// credential flag has a short form.
+			for j := 1; j < len(arg); j++ {
+				spec, known := lookupFlag(arg[j:j+1], true, command)
+				if !known || !spec.value {
+					continue
+				}
+				if strings.TrimPrefix(arg[j+1:], "=") == "" && i+1 < len(out) {
+					i++
+				}
+				break
+			}
+
+		default:
+			switch {
+			case passthrough:
+			case command == "":
+				command = arg
+			default:
+				positionals = append(positionals, i)
+			}
+		}
+	}
+
+	// kubectl config set users.NAME.token VALUE
+	if command == "config" && len(positionals) >= 3 && out[positionals[0]] == "set" && secretProperty(out[positionals[1]]) {
+		out[positionals[2]] = Redacted
+	}
+
+	if r != nil {
+		for i := range out {
+			out[i] = r.Text(out[i])
+		}
+	}
+	return out
+}
+
+// Text hides whatever in s matches the configured patterns.
+func (r *Redactor) Text(s string) string {
+	if r == nil {
+		return s
+	}
+	for _, re := range r.patterns {
+		s = re.ReplaceAllString(s, Redacted)
+	}
+	return s
+}
+
+// secretProperty reports whether a `kubectl config set` property path holds
+// a credential, e.g. users.admin.token or users.admin.client-key-data.
+func secretProperty(property string) bool {
+	name := property[strings.LastIndex(property, ".")+1:]
+	return strings.Contains(name, "token") ||
+		strings.Contains(name, "password") ||
+		strings.Contains(name, "secret") ||
+		strings.HasPrefix(name, "client-key")
+}
@@ -0,0 +1,381 @@
+package guard
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/cameronlockhart/kubectl-guard/config"
+)
+
+// Risk is the assessed risk of a state-altering command.
+type Risk struct {
+	Level config.RiskLevel
+	// Reasons explain why the level is higher than the command's base risk.
+	Reasons []string
+}
+
+// kindInfo describes a resource kind for risk assessment.
+type kindInfo struct {
+	// clusterScoped resources are not confined to a namespace.
+	clusterScoped bool
+	// risk is the risk of deleting resources of the kind.
+	risk config.RiskLevel
+}
+
+// kinds maps canonical resource names to their risk. Kinds not listed, such
+// as custom resources, are treated as namespaced and medium risk.
+var kinds = map[string]kindInfo{
+	"namespaces":                        {true, config.RiskCritical},
+	"customresourcedefinitions":         {true, config.RiskCritical},
+	"apiservices":                       {true, config.RiskHigh},
+	"nodes":                             {true, config.RiskHigh},
+	"persistentvolumes":                 {true, config.RiskHigh},
+	"storageclasses":                    {true, config.RiskHigh},
+	"clusterroles":                      {true, config.RiskHigh},
+	"clusterrolebindings":               {true, config.RiskHigh},
+	"mutatingwebhookconfigurations":     {true, config.RiskHigh},
+	"validatingwebhookconfigurations":   {true, config.RiskHigh},
+	"validatingadmissionpolicies":       {true, config.RiskHigh},
+	"validatingadmissionpolicybindings": {true, config.RiskHigh},
+	"certificatesigningrequests":        {true, config.RiskMedium},
+	"ingressclasses":                    {true, config.RiskMedium},
+	"priorityclasses":                   {true, config.RiskMedium},
+	"runtimeclasses":                    {true, config.RiskMedium},
+	"persistentvolumeclaims":            {false, config.RiskHigh},
+	"all":                               {false, config.RiskHigh},
+	"statefulsets":                      {false, config.RiskMedium},
+	"deployments":                       {false, config.RiskMedium},
+	"daemonsets":                        {false, config.RiskMedium},
+	"replicasets":                       {false, config.RiskMedium},
+	"replicationcontrollers":            {false, config.RiskMedium},
+	"cronjobs":                          {false, config.RiskMedium},
+	"services":                          {false, config.RiskMedium},
+	"ingresses":                         {false, config.RiskMedium},
+	"networkpolicies":                   {false, config.RiskMedium},
+	"secrets":                           {false, config.RiskMedium},
+	"configmaps":                        {false, config.RiskMedium},
+	"serviceaccounts":                   {false, config.RiskMedium},
+	"roles":                             {false, config.RiskMedium},
+	"rolebindings":                      {false, config.RiskMedium},
+	"resourcequotas":                    {false, config.RiskMedium},
+	"limitranges":                       {false, config.RiskMedium},
+	"poddisruptionbudgets":              {false, config.RiskMedium},
+	"horizontalpodautoscalers":          {false, config.RiskMedium},
+	"endpoints":                         {false, config.RiskMedium},
+	"jobs":                              {false, config.RiskLow},
+	"pods":                              {false, config.RiskLow},
+	"events":                            {false, config.RiskLow},
+	"leases":                            {false, config.RiskLow},
+}
+
+// kindAliases maps singular names and kubectl short names to canonical
+// resource names.
+var kindAliases = map[string]string{
+	"ns":                               "namespaces",
+	"namespace":                        "namespaces",
+	"crd":                              "customresourcedefinitions",
+	"crds":                             "customresourcedefinitions",
+	"customresourcedefinition":         "customresourcedefinitions",
+	"apiservice":                       "apiservices",
+	"no":                               "nodes",
+	"node":                             "nodes",
+	"pv":                               "persistentvolumes",
+	"persistentvolume":                 "persistentvolumes",
+	"sc":                               "storageclasses",
+	"storageclass":                     "storageclasses",
+	"clusterrole":                      "clusterroles",
+	"clusterrolebinding":               "clusterrolebindings",
+	"mutatingwebhookconfiguration":     "mutatingwebhookconfigurations",
+	"validatingwebhookconfiguration":   "validatingwebhookconfigurations",
+	"validatingadmissionpolicy":        "validatingadmissionpolicies",
+	"validatingadmissionpolicybinding": "validatingadmissionpolicybindings",
+	"csr":                              "certificatesigningrequests",
+	"certificatesigningrequest":        "certificatesigningrequests",
+	"ingressclass":                     "ingressclasses",
+	"pc":                               "priorityclasses",
+	"priorityclass":                    "priorityclasses",
+	"runtimeclass":                     "runtimeclasses",
+	"pvc":                              "persistentvolumeclaims",
+	"persistentvolumeclaim":            "persistentvolumeclaims",
+	"sts":                              "statefulsets",
+	"statefulset":                      "statefulsets",
+	"deploy":                           "deployments",
+	"deployment":                       "deployments",
+	"ds":                               "daemonsets",
+	"daemonset":                        "daemonsets",
+	"rs":                               "replicasets",
+	"replicaset":                       "replicasets",
+	"rc":                               "replicationcontrollers",
+	"replicationcontroller":            "replicationcontrollers",
+	"cj":                               "cronjobs",
+	"cronjob":                          "cronjobs",
+	"svc":                              "services",
+	"service":                          "services",
+	"ing":                              "ingresses",
+	"ingress":                          "ingresses",
+	"netpol":                           "networkpolicies",
+	"networkpolicy":                    "networkpolicies",
+	"secret":                           "secrets",
+	"cm":                               "configmaps",
+	"configmap":                        "configmaps",
+	"sa":                               "serviceaccounts",
+	"serviceaccount":                   "serviceaccounts",
+	"role":                             "roles",
+	"rolebinding":                      "rolebindings",
+	"quota":                            "resourcequotas",
+	"resourcequota":                    "resourcequotas",
+	"limits":                           "limitranges",
+	"limitrange":                       "limitranges",
+	"pdb":                              "poddisruptionbudgets",
+	"poddisruptionbudget":              "poddisruptionbudgets",
+	"hpa":                              "horizontalpodautoscalers",
+	"horizontalpodautoscaler":          "horizontalpodautoscalers",
+	"ep":                               "endpoints",
+	"job":                              "jobs",
+	"po":                               "pods",
+	"pod":                              "pods",
+	"ev":                               "events",
+	"event":                            "events",
+	"lease":                            "leases",
+}
+
+// commandRisk is the base risk of each state-altering command path, before
+// resource kinds and flags are considered. Commands not listed are medium.
+var commandRisk = map[string]config.RiskLevel{
+	"create":              config.RiskLow,
+	"expose":              config.RiskLow,
+	"run":                 config.RiskLow,
+	"label":               config.RiskLow,
+	"annotate":            config.RiskLow,
+	"autoscale":           config.RiskLow,
+	"rollout pause":       config.RiskLow,
+	"rollout resume":      config.RiskLow,
+	"uncordon":            config.RiskLow,
+	"port-forward":        config.RiskLow,
+	"proxy":               config.RiskLow,
+	"taint":               config.RiskHigh,
+	"drain":               config.RiskHigh,
+	"certificate approve": config.RiskHigh,
+	"auth reconcile":      config.RiskHigh,
+}
+
+// modifyingCommands change existing resources in place, so the kind's risk
+// applies one level lower than for a delete.
+var modifyingCommands = []string{"edit", "patch", "replace", "apply", "set", "rollout"}
+
+// impliedKinds are the resources commands operate on without a TYPE argument.
+var impliedKinds = map[string]string{
+	"cordon":      "nodes",
+	"uncordon":    "nodes",
+	"drain":       "nodes",
+	"certificate": "certificatesigningrequests",
+}
+
+// AssessRisk grades a classified command, including the objects in its
+// -f/-k manifests. Reads and local-only commands are always low risk.
+func AssessRisk(p ParsedCommand, c Classification, m Manifests) Risk {
+	if !c.Category.IsStateAltering() {
+		return Risk{Level: config.RiskLow}
+	}
+
+	r := Risk{Level: baseRisk(c)}
+	deletes := p.Command == "delete" || (p.Command == "replace" && p.Bool("force"))
+	modifies := slices.Contains(modifyingCommands, p.Command)
+	resources := resourceKinds(p, c, m)
+	if deletes && (len(resources) > 0 || m.Uninspected) {
+		// Deleting is graded by what is deleted, so a single pod is low.
+		r.Level = config.RiskLow
+	}
+
+	for _, kind := range resources {
+		info, known := kinds[kind]
+		if !known {
+			info = kindInfo{risk: config.RiskMedium}
+		}
+		switch {
+		case deletes:
+			r.raise(info.risk, "deletes "+kind)
+		case modifies && riskIndex(info.risk) >= riskIndex(config.RiskHigh):
+			r.raise(lower(info.risk), "modifies "+kind)
+		}
+		if info.clusterScoped {
+			if deletes {
+				r.raise(config.RiskHigh, kind+" are cluster-scoped")
+			} else {
+				r.raise(config.RiskMedium, kind+" are cluster-scoped")
+			}
+		}
+	}
+
+	if m.Uninspected {
+		// Manifests that could not be read may hold any kind, so they are
+		// graded like the riskiest one, a Namespace.
+		switch {
+		case deletes:
+			r.raise(config.RiskCritical, "deletes objects from manifests that were not inspected")
+		case modifies:
+			r.raise(config.RiskHigh, "modifies objects from manifests that were not inspected")
+		default:
+			r.raise(config.RiskMedium, "manifests that were not inspected may hold cluster-scoped objects")
+		}
+	}
+
+	if p.Command == "debug" && len(p.Args) > 0 && strings.HasPrefix(p.Args[0], "node/") {
+		r.raise(config.RiskHigh, "debugs a node with host access")
+	}
+	if p.Command == "apply" && p.Bool("prune") {
+		r.raise(config.RiskHigh, "--prune deletes resources missing from the manifests")
+	}
+
+	// Flags that widen the scope or skip safety nets make any write worse.
+	bulk := p.Bulk()
+	if bulk.AllNamespaces {
+		r.escalate("--all-namespaces spans every namespace")
+	}
+	switch {
+	case bulk.All:
+		r.escalate("--all selects every resource")
+	case bulk.Selector != "":
+		r.escalate("--selector can match many resources")
+	case bulk.FieldSelector != "":
+		r.escalate("--field-selector can match many resources")
+	}
+	if p.Bool("force") {
+		r.escalate("--force skips graceful handling")
+	} else if grace, ok := p.Flag("grace-period"); ok && grace == "0" {
+		r.escalate("--grace-period=0 kills pods immediately")
+	}
+	if p.Bool("disable-eviction") {
+		r.escalate("--disable-eviction bypasses PodDisruptionBudgets")
+	}
+
+	return r
+}
+
+// String returns the level, with reasons when there are any.
+func (r Risk) String() string {
+	if len(r.Reasons) == 0 {
+		return string(r.Level) + " risk"
+	}
+	return string(r.Level) + " risk: " + strings.Join(r.Reasons, "; ")
+}
+
+// raise lifts the risk to at least level.
+func (r *Risk) raise(level config.RiskLevel, reason string) {
+	if riskIndex(level) > riskIndex(r.Level) {
+		r.Level = level
+		r.Reasons = append(r.Reasons, reason)
+	}
+}
+
+// escalate lifts the risk one level.
+func (r *Risk) escalate(reason string) {
+	if i := riskIndex(r.Level); i < len(config.RiskLevels)-1 {
+		r.Level = config.RiskLevels[i+1]
+		r.Reasons = append(r.Reasons, reason)
+	}
+}
+
+// baseRisk returns the risk of a command path before kinds and flags.
+func baseRisk(c Classification) config.RiskLevel {
+	if c.Category == CategoryWriteKubeconfig {
+		return config.RiskLow
+	}
+	for n := len(c.Path); n > 0; n-- {
+		if level, ok := commandRisk[strings.Join(c.Path[:n], " ")]; ok {
+			return level
+		}
+	}
+	return config.RiskMedium
+}
+
+// resourceKinds returns the canonical resource kinds a command names, e.g.
+// "pods" and "services" for "delete pod/a svc/b", and the kinds of the
+// objects in its manifests.
+func resourceKinds(p ParsedCommand, c Classification, m Manifests) []string {
+	types, _ := resourceArgs(p, c)
+	for _, obj := range m.Objects {
+		types = append(types, obj.Kind)
+	}
+	return normalizeKinds(types)
+}
+
+// resourceArgs splits a command's positional arguments, after its command
+// path, into resource types and names. Label, annotation, taint and env
+// changes ("app=web", "app-") are not names.
+func resourceArgs(p ParsedCommand, c Classification) (types, names []string) {
+	if len(c.Path) == 0 {
+		return nil, nil
+	}
+	args := p.Args[len(c.Path)-1:]
+	if kind, ok := impliedKinds[p.Command]; ok {
+		return []string{kind}, args
+	}
+
+	switch p.Command {
+	case "create", "set":
+		// "create namespace x", "set image deploy/x": create names the kind
+		// as a subcommand, set takes TYPE after its subcommand.
+		if len(args) == 0 {
+			return nil, nil
+		}
+		if p.Command == "create" {
+			return args[:1], args[1:min(2, len(args))]
+		}
+		args = args[1:]
+	case "run", "expose", "exec", "attach", "cp", "debug", "port-forward", "proxy", "config", "auth", "alpha":
+		return nil, nil
+	}
+	if len(args) == 0 {
+		return nil, nil
+	}
+
+	// TYPE/NAME arguments may each name a different kind; otherwise the
+	// first argument is TYPE or TYPE1,TYPE2.
+	if strings.Contains(args[0], "/") {
+		for _, arg := range args {
+			if t, name, ok := strings.Cut(arg, "/"); ok {
+				types = append(types, t)
+				names = append(names, name)
+			}
+		}
+		return types, names
+	}
+	for _, arg := range args[1:] {
+		if strings.Contains(arg, "=") || strings.HasSuffix(arg, "-") {
+			continue
+		}
+		names = append(names, arg)
+	}
+	return strings.Split(args[0], ","), names
+}
+
+// normalizeKinds maps resource names to canonical plural names, dropping API
+// groups ("deployments.apps") and duplicates.
+func normalizeKinds(types []string) []string {
+	var out []string
+	for _, t := range types {
+		t = strings.ToLower(t)
+		t, _, _ = strings.Cut(t, ".")
+		if canonical, ok := kindAliases[t]; ok {
+			t = canonical
+		}
+		if t != "" && !slices.Contains(out, t) {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// riskIndex orders risk levels; unknown levels sort lowest.
+func riskIndex(level config.RiskLevel) int {
+	return slices.Index(config.RiskLevels, level)
+}
+
+// lower returns the level one below, or low.
+func lower(level config.RiskLevel) config.RiskLevel {
+	if i := riskIndex(level); i > 0 {
+		return config.RiskLevels[i-1]
+	}
+	return config.RiskLow
+}
@@ -0,0 +1,105 @@
+package guard
+
+import (
+	"os"
+	"testing"
+
+	"github.com/cameronlockhart/kubectl-guard/config"
+)
+
+// assess parses, classifies and grades args, reading their manifests.
+func assess(args []string) Risk {
+	p := ParseCommand(args)
+	return AssessRisk(p, Classify(p), p.Manifests(nil))
+}
+
+func TestAssessRisk(t *testing.T) {
+	t.Chdir(t.TempDir())
+	manifests := map[string]string{
+		"manifest.yaml":  "kind: Deployment\nmetadata:\n  name: web\n",
+		"x.yaml":         "kind: Deployment\nmetadata:\n  name: web\n",
+		"pods.yaml":      "kind: Pod\nmetadata:\n  name: web-0\n",
+		"namespace.yaml": "kind: Namespace\nmetadata:\n  name: payments\n",
+	}
+	for name, data := range manifests {
+		if err := os.WriteFile(name, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		args []string
+		want config.RiskLevel
+	}{
+		// Reads are never risky
+		{[]string{"get", "namespaces"}, config.RiskLow},
+		{[]string{"delete", "ns", "x", "--dry-run=server"}, config.RiskLow},
+
+		// Deletes are graded by kind
+		{[]string{"delete", "pod", "nginx-abc"}, config.RiskLow},
+		{[]string{"delete", "deployment", "web"}, config.RiskMedium},
+		{[]string{"delete", "deploy.apps/web"}, config.RiskMedium},
+		{[]string{"delete", "pvc", "data-0"}, config.RiskHigh},
+		{[]string{"delete", "clusterrole", "admin"}, config.RiskHigh},
+		{[]string{"delete", "namespace", "payments"}, config.RiskCritical},
+		{[]string{"delete", "crd", "widgets.example.com"}, config.RiskCritical},
+		{[]string{"delete", "pod/a", "ns/b"}, config.RiskCritical},
+		{[]string{"delete", "pods,services", "x"}, config.RiskMedium},
+		{[]string{"delete", "widgets.example.com", "x"}, config.RiskMedium},
+		{[]string{"delete", "-f", "manifest.yaml"}, config.RiskMedium},
+		{[]string{"replace", "--force", "-f", "x.yaml"}, config.RiskHigh},
+
+		// Manifests are graded by the kinds they hold; those that can't be
+		// read could hold anything
+		{[]string{"delete", "-f", "pods.yaml"}, config.RiskLow},
+		{[]string{"delete", "-f", "namespace.yaml"}, config.RiskCritical},
+		{[]string{"apply", "-f", "namespace.yaml"}, config.RiskHigh},
+		{[]string{"delete", "-f", "-"}, config.RiskCritical},
+		{[]string{"delete", "-f", "https://example.com/app.yaml"}, config.RiskCritical},
+		{[]string{"apply", "-f", "-"}, config.RiskHigh},
+		{[]string{"create", "-f", "-"}, config.RiskMedium},
+		{[]string{"delete", "-f", "missing.yaml"}, config.RiskCritical},
+
+		// Selectors, scope and force escalate
+		{[]string{"delete", "pods", "--all"}, config.RiskMedium},
+		{[]string{"delete", "pods", "-l", "app=web"}, config.RiskMedium},
+		{[]string{"delete", "deploy", "--all", "-A"}, config.RiskCritical},
+		{[]string{"delete", "pod", "x", "--grace-period=0", "--force"}, config.RiskMedium},
+
+		// Other verbs
+		{[]string{"label", "pod", "x", "a=b"}, config.RiskLow},
+		{[]string{"label", "node", "x", "a=b"}, config.RiskMedium},
+		{[]string{"create", "namespace", "x"}, config.RiskMedium},
+		{[]string{"create", "deployment", "x", "--image=nginx"}, config.RiskLow},
+		{[]string{"scale", "deploy", "web", "--replicas=0"}, config.RiskMedium},
+		{[]string{"patch", "crd", "widgets.example.com", "-p", "{}"}, config.RiskHigh},
+		{[]string{"set", "image", "deploy/web", "web=nginx"}, config.RiskMedium},
+		{[]string{"apply", "-f", "x.yaml"}, config.RiskMedium},
+		{[]string{"apply", "-f", "x.yaml", "--prune", "-l", "app=web"}, config.RiskCritical},
+		{[]string{"rollout", "pause", "deploy/web"}, config.RiskLow},
+		{[]string{"cordon", "node-1"}, config.RiskMedium},
+		{[]string{"drain", "node-1"}, config.RiskHigh},
+		{[]string{"drain", "node-1", "--disable-eviction"}, config.RiskCritical},
+		{[]string{"exec", "-it", "web", "--", "sh"}, config.RiskMedium},
+		{[]string{"debug", "node/node-1", "-it", "--image=busybox"}, config.RiskHigh},
+		{[]string{"port-forward", "svc/web", "8080:80"}, config.RiskLow},
+		{[]string{"config", "delete-context", "prod"}, config.RiskLow},
+	}
+
+	for _, tt := range tests {
+		if got := assess(tt.args); got.Level != tt.want {
+			t.Errorf("AssessRisk(%v) = %v, want %s", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestRiskReasons(t *testing.T) {
+	r := assess([]string{"delete", "ns", "payments"})
+	if want := "critical risk: deletes namespaces"; r.String() != want {
+		t.Errorf("AssessRisk(delete ns).String() = %q, want %q", r.String(), want)
+	}
+
+	if r := assess([]string{"delete", "pod", "x"}); r.String() != "low risk" {
+		t.Errorf("AssessRisk(delete pod).String() = %q, want %q", r.String(), "low risk")
+	}
+}
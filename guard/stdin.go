@@ -0,0 +1,76 @@
+package guard
+
+import (
+	"io"
+	"os"
+	"slices"
+)
+
+// Stdin holds piped standard input read ahead of a confirmation prompt, so
+// manifests given with "-f -" can be inspected and then handed to kubectl
+// intact.
+type Stdin struct {
+	data []byte
+	// feeding is set once Restore has left a goroutine writing the data
+	// into a pipe.
+	feeding bool
+}
+
+// ReadsStdinManifests reports whether the command reads manifests from
+// stdin with "-f -".
+func (p ParsedCommand) ReadsStdinManifests() bool {
+	return slices.Contains(p.Flags["filename"], "-")
+}
+
+// BufferStdin reads all of stdin if it is a pipe or file. It returns nil
+// when stdin is a terminal, which kubectl can read directly.
+func BufferStdin() (*Stdin, error) {
+	info, err := os.Stdin.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Mode()&os.ModeCharDevice != 0 {
+		return nil, nil
+	}
+
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return nil, err
+	}
+	return &Stdin{data: data}, nil
+}
+
+// Bytes returns the buffered input. It is safe to call on a nil Stdin.
+func (s *Stdin) Bytes() []byte {
+	if s == nil {
+		return nil
+	}
+	return s.data
+}
+
+// Restore makes the buffered input available on standard input again, so
+// kubectl reads it from the start. The input is kept in memory and never
+// written to disk, since it may hold secrets. It is a no-op on a nil Stdin.
+func (s *Stdin) Restore() error {
+	if s == nil {
+		return nil
+	}
+	return s.restoreTo(int(os.Stdin.Fd()))
+}
+
+// Feeding reports whether the restored input is written into a pipe by
+// this process. kubectl must then run as a child: replacing this process
+// would cut the input short. It is false for a nil Stdin.
+func (s *Stdin) Feeding() bool {
+	return s != nil && s.feeding
+}
+
+// feed writes the data into w from a goroutine, since a pipe holds only so
+// much, and closes it so the reader sees the end of the input.
+func (s *Stdin) feed(w *os.File) {
+	s.feeding = true
+	go func() {
+		w.Write(s.data)
+		w.Close()
+	}()
+}
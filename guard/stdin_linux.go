@@ -0,0 +1,27 @@
+package guard
+
+import (
+	"io"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// restoreTo replaces fd with an anonymous in-memory file holding the data.
+// It survives kubectl replacing this process.
+func (s *Stdin) restoreTo(fd int) error {
+	mfd, err := unix.MemfdCreate("kubectl-guard-stdin", unix.MFD_CLOEXEC)
+	if err != nil {
+		return err
+	}
+	f := os.NewFile(uintptr(mfd), "kubectl-guard-stdin")
+	defer f.Close()
+
+	if _, err := f.Write(s.data); err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return unix.Dup2(mfd, fd)
+}
@@ -0,0 +1,18 @@
+//go:build !unix
+
+package guard
+
+import "os"
+
+// restoreTo replaces standard input with the read end of a pipe the data is
+// fed into. Without dup2, fd can't be replaced in place, so os.Stdin is,
+// and fd must be standard input's.
+func (s *Stdin) restoreTo(fd int) error {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	os.Stdin = r
+	s.feed(w)
+	return nil
+}
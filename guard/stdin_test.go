@@ -0,0 +1,95 @@
+package guard
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestReadsStdinManifests(t *testing.T) {
+	tests := []struct {
+		args []string
+		want bool
+	}{
+		{[]string{"apply", "-f", "-"}, true},
+		{[]string{"apply", "--filename=-"}, true},
+		{[]string{"apply", "-f", "x.yaml"}, false},
+		{[]string{"exec", "-i", "web", "--", "sh"}, false},
+	}
+	for _, tt := range tests {
+		if got := ParseCommand(tt.args).ReadsStdinManifests(); got != tt.want {
+			t.Errorf("ReadsStdinManifests(%v) = %v, want %v", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestBufferStdinAndRestore(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.yaml")
+	if err := os.WriteFile(in, []byte(testManifests), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	orig := os.Stdin
+	os.Stdin = f
+	defer func() { os.Stdin = orig }()
+
+	stdin, err := BufferStdin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(stdin.Bytes()) != testManifests {
+		t.Fatalf("BufferStdin() = %q, want the piped manifests", stdin.Bytes())
+	}
+
+	// The buffer feeds the prompt's manifest listing.
+	m := ParseCommand([]string{"apply", "-f", "-"}).Manifests(stdin.Bytes())
+	if got := objectNames(m); !slices.Equal(got, []string{"Deployment/web", "ConfigMap/web-config", "Namespace/payments"}) {
+		t.Errorf("stdin manifests = %v", got)
+	}
+
+	// Restoring replays the input from the start on the descriptor,
+	// without writing it to disk.
+	target, err := os.Create(filepath.Join(dir, "target"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer target.Close()
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	if err := stdin.restoreTo(int(target.Fd())); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := os.ReadDir(tmp); len(entries) > 0 {
+		t.Errorf("restoring wrote %s to the temporary directory", entries[0].Name())
+	}
+	data, err := io.ReadAll(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != testManifests {
+		t.Errorf("restored input = %q, want the piped manifests", data)
+	}
+}
+
+func TestNilStdin(t *testing.T) {
+	var stdin *Stdin
+	if stdin.Bytes() != nil {
+		t.Error("nil Stdin Bytes() != nil")
+	}
+	if err := stdin.Restore(); err != nil {
+		t.Errorf("nil Stdin Restore() = %v", err)
+	}
+
+	m := ParseCommand([]string{"apply", "-f", "-"}).Manifests(nil)
+	if len(m.Objects) != 0 || len(m.Warnings) != 1 {
+		t.Errorf("unbuffered stdin manifests = %+v, want one warning", m)
+	}
+}
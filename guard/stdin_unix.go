@@ -0,0 +1,24 @@
+//go:build unix && !linux
+
+package guard
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// restoreTo replaces fd with the read end of a pipe the data is fed into.
+func (s *Stdin) restoreTo(fd int) error {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	if err := unix.Dup2(int(r.Fd()), fd); err != nil {
+		w.Close()
+		return err
+	}
+	s.feed(w)
+	return nil
+}
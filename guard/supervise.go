@@ -0,0 +1,82 @@
+package guard
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Exit describes how a supervised kubectl ended.
+type Exit struct {
+	// Code is kubectl's exit status, or 128 plus the signal number when a
+	// signal killed it, as a shell reports it.
+	Code int
+	// Signal names the signal that killed kubectl, if one did.
+	Signal string
+	// Duration is how long kubectl ran.
+	Duration time.Duration
+}
+
+// SuperviseTarget runs kubectl as a child process pinned to the target, so
+// its outcome can be recorded. kubectl shares this process's terminal,
+// stdin, stdout and stderr, and receives the signals it is sent. An error
+// means kubectl could not be started or waited for.
+func SuperviseTarget(args []string, t Target) (Exit, error) {
+	return superviseKubectl(t.Pin(args), t.Environ())
+}
+
+func superviseKubectl(args []string, env []string) (Exit, error) {
+	kubectl, err := exec.LookPath("kubectl")
+	if err != nil {
+		return Exit{}, err
+	}
+
+	cmd := exec.Command(kubectl, args...)
+	cmd.Env = env
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	// Catch signals before kubectl starts, so none is missed or kills the
+	// supervisor instead.
+	signals := make(chan os.Signal, len(forwardedSignals))
+	signal.Notify(signals, forwardedSignals...)
+	defer signal.Stop(signals)
+
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		return Exit{}, err
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				_ = cmd.Process.Signal(sig)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	err = cmd.Wait()
+	exit := Exit{Duration: time.Since(start)}
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return exit, err
+	}
+
+	status, ok := cmd.ProcessState.Sys().(syscall.WaitStatus)
+	switch {
+	case ok && status.Signaled():
+		exit.Signal = status.Signal().String()
+		exit.Code = 128 + int(status.Signal())
+	default:
+		exit.Code = cmd.ProcessState.ExitCode()
+	}
+	return exit, nil
+}
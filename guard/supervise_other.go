@@ -0,0 +1,13 @@
+//go:build !unix
+
+package guard
+
+import (
+	"os"
+	"syscall"
+)
+
+// forwardedSignals are passed on to a supervised kubectl. Ctrl-C reaches
+// kubectl through the console as well, so forwarding it mostly keeps it
+// from stopping the supervisor first.
+var forwardedSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
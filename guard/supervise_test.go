@@ -0,0 +1,10 @@
+package guard
+
+import "testing"
+
+func TestSuperviseMissingKubectl(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	if _, err := SuperviseTarget([]string{"get", "pods"}, Target{}); err == nil {
+		t.Error("SuperviseTarget() without kubectl: error = nil")
+	}
+}
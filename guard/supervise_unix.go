@@ -0,0 +1,13 @@
+//go:build unix
+
+package guard
+
+import (
+	"os"
+	"syscall"
+)
+
+// forwardedSignals are passed on to a supervised kubectl. Signals the
+// terminal sends to the whole process group, such as SIGINT from Ctrl-C,
+// may reach kubectl twice; kubectl treats the second like the first.
+var forwardedSignals = []os.Signal{syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT, syscall.SIGWINCH}
@@ -0,0 +1,80 @@
+//go:build unix
+
+package guard
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// fakeKubectl puts a kubectl shell script on PATH.
+func fakeKubectl(t *testing.T, script string) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "kubectl"), []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestSuperviseExitCode(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "args")
+	fakeKubectl(t, `echo "$@" > `+out+`; exit 3`)
+
+	exit, err := SuperviseTarget([]string{"delete", "pod", "x"}, Target{Context: "prod"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exit.Code != 3 || exit.Signal != "" {
+		t.Errorf("exit = %+v, want code 3 without a signal", exit)
+	}
+	args, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(args)); got != "--context=prod delete pod x" {
+		t.Errorf("kubectl ran with %q, want it pinned to prod", got)
+	}
+}
+
+func TestSuperviseSignaled(t *testing.T) {
+	fakeKubectl(t, `kill -KILL $$`)
+
+	exit, err := SuperviseTarget([]string{"delete", "pod", "x"}, Target{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exit.Code != 128+int(syscall.SIGKILL) || exit.Signal != syscall.SIGKILL.String() {
+		t.Errorf("exit = %+v, want code %d and signal killed", exit, 128+int(syscall.SIGKILL))
+	}
+}
+
+func TestSuperviseForwardsSignals(t *testing.T) {
+	ready := filepath.Join(t.TempDir(), "ready")
+	fakeKubectl(t, `trap 'exit 42' TERM; touch `+ready+`; while :; do sleep 0.01; done`)
+
+	go func() {
+		for range 500 {
+			if _, err := os.Stat(ready); err == nil {
+				_ = syscall.Kill(os.Getpid(), syscall.SIGTERM)
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	exit, err := SuperviseTarget([]string{"get", "pods", "-w"}, Target{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exit.Code != 42 {
+		t.Errorf("exit = %+v, want code 42 from the forwarded SIGTERM", exit)
+	}
+	if exit.Duration <= 0 {
+		t.Errorf("duration = %v, want it measured", exit.Duration)
+	}
+}
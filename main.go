@@ -1,10 +1,14 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"slices"
 	"strings"
 
+	"github.com/cameronlockhart/kubectl-guard/audit"
 	"github.com/cameronlockhart/kubectl-guard/config"
 	"github.com/cameronlockhart/kubectl-guard/guard"
 	"github.com/cameronlockhart/kubectl-guard/ui"
@@ -13,6 +17,13 @@ import (
 
 var version = "dev"
 
+// Exit codes for commands kubectl-guard refuses to run.
+const (
+	exitDeniedByUser   = 1 // the user declined the confirmation
+	exitDeniedByPolicy = 3 // the context's mode or the non-interactive policy refuses the command
+	exitNoTerminal     = 4 // confirmation is required but there is no terminal and no policy
+)
+
 func main() {
 	if err := run(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
@@ -25,10 +36,24 @@ func run() error {
 	if len(os.Args) > 1 {
 		switch os.Args[1] {
 		case "config":
-			return runConfigCommand()
+			// Everything else under config, e.g. "config use-context", is
+			// kubectl's and is checked like any other command.
+			if len(os.Args) > 2 && slices.Contains(configCommands, os.Args[2]) {
+				return runConfigCommand()
+			}
+		case "audit":
+			return runAuditCommand()
+		case "explain":
+			if args, asJSON := explainArgs(os.Args[2:]); guard.ExplainsCommand(args) {
+				return runExplain(args, asJSON)
+			}
 		case "--version", "-v":
-			fmt.Printf("kubectl-guard %s\n", version)
-			return nil
+			// kubectl uses -v for log verbosity ("-v 6 get pods"), so only
+			// a bare -v prints the version.
+			if len(os.Args) == 2 {
+				fmt.Printf("kubectl-guard %s\n", version)
+				return nil
+			}
 		case "--help", "-h":
 			printHelp()
 			return nil
@@ -39,14 +64,62 @@ func run() error {
 	return runGuard(os.Args[1:])
 }
 
+// protectedMessage describes a guarded command on a protected context.
+// stdin is the buffered input, if any, for listing "-f -" manifests.
+func protectedMessage(d guard.Decision, stdin []byte) string {
+	target := d.Target
+	cmdDesc := guard.GetCommandDescription(d.RedactedArgs())
+	if d.Classification.Category == guard.CategoryUnknown {
+		cmdDesc = fmt.Sprintf("unrecognized command %q", cmdDesc)
+		return fmt.Sprintf("%s in %s on protected context: %s", cmdDesc, target.NamespaceDescription(), target)
+	}
+	message := fmt.Sprintf("%s in %s on protected context: %s (%s)", cmdDesc, target.NamespaceDescription(), target, d.Risk)
+	for _, line := range d.Manifests(stdin).Describe(target) {
+		message += "\n    " + line
+	}
+	return message
+}
+
 func runGuard(args []string) error {
-	result, ctx, err := guard.Check(args)
+	// --guard-* flags are for kubectl-guard and never reach kubectl.
+	opts, args, err := guard.ParseOptions(args)
 	if err != nil {
-		// On error, still try to run kubectl
-		return guard.ExecKubectl(args)
+		return err
+	}
+	if opts.ExpectContext != "" {
+		target, err := guard.ResolveTarget(args)
+		if err != nil {
+			ui.PrintError(fmt.Sprintf("Not run: could not resolve the context to check --guard-expect-context: %v", err))
+			os.Exit(exitDeniedByPolicy)
+		}
+		if !opts.ContextExpected(target) {
+			ui.PrintError(fmt.Sprintf("Not run: the command would use context %s, not %s (--guard-expect-context).", target, opts.ExpectContext))
+			os.Exit(exitDeniedByPolicy)
+		}
+	}
+
+	if opts.Explain {
+		return printExplanation(guard.Explain(args), false)
+	}
+
+	// A broken config or kubeconfig doesn't silently disable protection:
+	// the decision follows on_error, and checkErr is shown in every prompt.
+	d, checkErr := guard.Check(args)
+	inv := &invocation{args: args, opts: opts, decision: d, checkErr: checkErr}
+
+	// Prompts read from the terminal, so manifests piped to "-f -" can be
+	// buffered, listed in the prompt and replayed to kubectl intact.
+	if d.Action != guard.Allow && d.Action != guard.SetupRequired && d.Command.ReadsStdinManifests() {
+		if inv.stdin, err = guard.BufferStdin(); err != nil {
+			return fmt.Errorf("could not read stdin: %w", err)
+		}
+	}
+
+	if checkErr != nil && d.Action == guard.Allow && d.Mode != config.ModeAllow {
+		ui.PrintBanner(inv.message())
 	}
 
-	switch result {
+	switch d.Action {
 	case guard.SetupRequired:
 		contexts, err := guard.GetAllContexts()
 		if err != nil {
@@ -61,22 +134,255 @@ func runGuard(args []string) error {
 		config.RunSetup(contextNames)
 		return nil
 
+	case guard.Warn:
+		ui.PrintBanner(inv.message())
+		return inv.run(audit.OutcomeWarned, "")
+
 	case guard.RequireConfirmation:
-		cmdDesc := guard.GetCommandDescription(args)
-		message := fmt.Sprintf("%s on protected context: %s", cmdDesc, ctx)
-		if ui.Confirm(message) {
-			return guard.ExecKubectl(args)
+		if approval, ok := assumedYes(opts); ok {
+			return inv.approved(approval)
 		}
-		fmt.Println("Aborted.")
-		os.Exit(1)
+		var confirmed bool
+		if d.IsBulk() {
+			confirmed, err = ui.ConfirmBulk(inv.message(), d.BlastRadius())
+		} else {
+			confirmed, err = ui.Confirm(inv.message())
+		}
+		return inv.afterPrompt(confirmed, err)
+
+	case guard.RequireTypedConfirmation:
+		if approval, ok := assumedYes(opts); ok {
+			return inv.approved(approval)
+		}
+		if d.IsBulk() {
+			ui.PrintBlastRadius(d.BlastRadius())
+		}
+		confirmed, err := ui.ConfirmTyped(inv.message(), d.Target.Context)
+		return inv.afterPrompt(confirmed, err)
+
+	case guard.Block:
+		ui.PrintError(inv.message())
+		switch {
+		case checkErr != nil:
+			ui.PrintError("Blocked: commands that can't be checked are not allowed (on_error: block).")
+		case d.Classification.Category == guard.CategoryUnknown:
+			ui.PrintError("Blocked: unrecognized commands are not allowed on this context.")
+		default:
+			ui.PrintError("Blocked: state-altering commands are not allowed on this context.")
+		}
+		inv.record(audit.OutcomeBlocked, "", nil)
+		os.Exit(exitDeniedByPolicy)
 
 	case guard.Allow:
-		return guard.ExecKubectl(args)
+		return inv.run(audit.OutcomeAllowed, "")
+	}
+
+	return nil
+}
+
+// invocation is a checked kubectl command on its way to kubectl.
+type invocation struct {
+	args     []string
+	opts     guard.Options
+	decision guard.Decision
+	// checkErr is why the command couldn't be fully checked, if it couldn't.
+	checkErr error
+	// stdin is piped input buffered for "-f -", if any.
+	stdin *guard.Stdin
+}
+
+// message describes the command for banners and prompts.
+func (inv *invocation) message() string {
+	if inv.checkErr != nil {
+		return fmt.Sprintf("%s could not be checked: %v", guard.GetCommandDescription(inv.decision.RedactedArgs()), inv.checkErr)
 	}
+	return protectedMessage(inv.decision, inv.stdin.Bytes())
+}
 
+// run records the decision and hands the command to kubectl, pinned to the
+// checked target. Normally kubectl replaces this process. Supervised
+// commands, and commands whose stdin this process is still feeding, run
+// kubectl as a child instead, are recorded with its exit status once it
+// ends, and exit with that status.
+func (inv *invocation) run(outcome audit.Outcome, approval guard.Approval) error {
+	if err := inv.stdin.Restore(); err != nil {
+		return fmt.Errorf("could not replay stdin to kubectl: %w", err)
+	}
+	if !inv.decision.Supervise && !inv.stdin.Feeding() {
+		inv.record(outcome, approval, nil)
+		return guard.ExecTarget(inv.args, inv.decision.Target)
+	}
+
+	exit, err := guard.SuperviseTarget(inv.args, inv.decision.Target)
+	if err != nil {
+		inv.record(outcome, approval, nil)
+		return err
+	}
+	inv.record(outcome, approval, &exit)
+	os.Exit(exit.Code)
 	return nil
 }
 
+// record appends the decision and its outcome to the audit log, with how
+// kubectl ended if it was supervised. A log that can't be written is
+// reported but doesn't stop the command.
+func (inv *invocation) record(outcome audit.Outcome, approval guard.Approval, exit *guard.Exit) {
+	if !audit.Records(inv.decision, inv.checkErr) {
+		return
+	}
+	r := audit.NewRecord(inv.decision, inv.checkErr)
+	r.Outcome = outcome
+	r.Confirmed = outcome == audit.OutcomeConfirmed || outcome == audit.OutcomeApproved
+	r.Approval = string(approval)
+	r.Reason = inv.opts.Reason
+	if exit != nil {
+		r.SetExit(*exit)
+	}
+
+	log, err := audit.OpenConfigured()
+	if err == nil {
+		err = log.Append(r)
+	}
+	if err != nil {
+		ui.PrintError("Audit: " + err.Error())
+	}
+}
+
+// afterPrompt runs kubectl if the user confirmed, and otherwise exits with
+// the code for a declined or impossible confirmation. Without a terminal,
+// the non-interactive policy decides.
+func (inv *invocation) afterPrompt(confirmed bool, err error) error {
+	if errors.Is(err, ui.ErrNoTerminal) {
+		approval, err := guard.ApproveNonInteractive()
+		switch {
+		case err == nil:
+			return inv.approved(approval)
+		case errors.Is(err, guard.ErrDeniedByPolicy):
+			ui.PrintError(inv.message())
+			ui.PrintError("Denied: there is no terminal to confirm on, and the non-interactive policy refuses the command.")
+			inv.record(audit.OutcomeDenied, "", nil)
+			os.Exit(exitDeniedByPolicy)
+		default:
+			ui.PrintError(inv.message())
+			ui.PrintError("Not run: this command needs confirmation, but there is no terminal to ask on.")
+			inv.record(audit.OutcomeNoTerminal, "", nil)
+			os.Exit(exitNoTerminal)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if !confirmed {
+		inv.record(audit.OutcomeAborted, "", nil)
+		fmt.Println("Aborted.")
+		os.Exit(exitDeniedByUser)
+	}
+	return inv.run(audit.OutcomeConfirmed, "")
+}
+
+// assumedYes returns what answers the prompt in advance, if anything:
+// --guard-yes or KUBECTL_GUARD_ASSUME_YES.
+func assumedYes(opts guard.Options) (guard.Approval, bool) {
+	switch {
+	case opts.Yes:
+		return guard.ApprovedGuardYes, true
+	case guard.AssumeYes():
+		return guard.ApprovedAssumeYes, true
+	}
+	return "", false
+}
+
+// approved runs a command that was approved without a prompt, first
+// recording on stderr and in the audit log what approved it and why.
+func (inv *invocation) approved(approval guard.Approval) error {
+	ui.PrintBanner(inv.message())
+	if inv.opts.Reason != "" {
+		ui.PrintBanner(fmt.Sprintf("Approved without confirmation by %s (reason: %s).", approval, inv.opts.Reason))
+	} else {
+		ui.PrintBanner(fmt.Sprintf("Approved without confirmation by %s.", approval))
+	}
+	return inv.run(audit.OutcomeApproved, approval)
+}
+
+// explainArgs separates the --json (or -o json) flag of the explain
+// subcommand, given before the kubectl arguments, from those arguments.
+func explainArgs(args []string) ([]string, bool) {
+	switch {
+	case len(args) > 0 && args[0] == "--json":
+		return args[1:], true
+	case len(args) > 1 && args[0] == "-o" && args[1] == "json":
+		return args[2:], true
+	}
+	return args, false
+}
+
+// runExplain prints how kubectl-guard would handle a kubectl command,
+// without running it.
+func runExplain(args []string, asJSON bool) error {
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+	_, args, err := guard.ParseOptions(args)
+	if err != nil {
+		return err
+	}
+	return printExplanation(guard.Explain(args), asJSON)
+}
+
+// printExplanation prints an explanation as text or JSON.
+func printExplanation(e guard.Explanation, asJSON bool) error {
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(e)
+	}
+
+	field := func(name, value string) {
+		if value != "" {
+			fmt.Printf("%-13s %s\n", name+":", value)
+		}
+	}
+	field("Command", strings.TrimSpace(e.Command+" "+strings.Join(e.Arguments, " ")))
+	field("Category", string(e.Category))
+	field("Dry run", e.DryRun)
+	field("Context", e.Context)
+	field("Cluster", e.Cluster)
+	field("Server", e.Server)
+	if e.AllNamespaces {
+		field("Namespace", "all namespaces")
+	} else {
+		field("Namespace", e.Namespace)
+	}
+	if e.Protected {
+		field("Rule", e.Rule)
+	} else if e.Context != "" {
+		field("Rule", "none (not protected)")
+	}
+	if e.Risk != "" {
+		field("Risk", string(e.Risk))
+		for _, reason := range e.RiskReasons {
+			fmt.Printf("%-13s - %s\n", "", reason)
+		}
+	}
+	field("Mode", string(e.Mode))
+	field("Error", e.Error)
+	field("Decision", e.Decision)
+	if len(e.BlastRadius) > 0 {
+		fmt.Println("Blast radius:")
+		for _, line := range e.BlastRadius {
+			fmt.Printf("  - %s\n", line)
+		}
+	}
+	fmt.Println("Reasons:")
+	for _, reason := range e.Reasons {
+		fmt.Printf("  - %s\n", reason)
+	}
+	return nil
+}
+
+// configCommands are kubectl-guard's own config subcommands.
+var configCommands = []string{"setup", "list", "add", "remove", "path"}
+
 func runConfigCommand() error {
 	rootCmd := &cobra.Command{
 		Use:   "config",
@@ -118,46 +424,122 @@ func runConfigCommand() error {
 				return err
 			}
 
-			if len(cfg.ProtectedContexts) == 0 {
+			entries := cfg.Entries()
+			if len(entries) == 0 {
 				ui.PrintInfo("No protected contexts.")
 				return nil
 			}
 
+			// Resolve each entry against the current kubeconfig so renamed
+			// or duplicate contexts are visible.
+			var targets []guard.Target
+			if kc, err := guard.LoadKubeconfig(""); err == nil {
+				for _, name := range kc.ContextNames() {
+					targets = append(targets, kc.Target(name))
+				}
+			} else {
+				ui.PrintWarning("Could not read kubeconfig: " + err.Error())
+			}
+
 			ui.PrintInfo("Protected contexts:")
-			for _, ctx := range cfg.ProtectedContexts {
-				fmt.Printf("  - %s\n", ctx)
+			for _, rule := range entries {
+				var matches []string
+				for _, t := range targets {
+					if rule.MatchesCluster(t.ConfigTarget()) {
+						matches = append(matches, t.Context)
+					}
+				}
+				if len(matches) > 0 {
+					fmt.Printf("  - %s → %s\n", describeRule(rule), strings.Join(matches, ", "))
+				} else {
+					fmt.Printf("  - %s (no matching contexts)\n", describeRule(rule))
+				}
 			}
 			return nil
 		},
 	})
 
-	rootCmd.AddCommand(&cobra.Command{
-		Use:   "add <context>",
-		Short: "Add a context to the protected list",
-		Args:  cobra.ExactArgs(1),
+	var addRule config.Rule
+	var addMode, addUnknown string
+	var addRiskModes map[string]string
+	addCmd := &cobra.Command{
+		Use:   "add [context]",
+		Short: "Add a context, or a cluster/server/CA rule, to the protected list",
+		Args:  cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			rule, err := ruleFromArgs(addRule, args)
+			if err != nil {
+				return err
+			}
+			if addMode != "" {
+				if rule.Mode, err = config.ParseMode(addMode); err != nil {
+					return err
+				}
+			}
+			if addUnknown != "" {
+				if rule.UnknownCommands, err = config.ParseMode(addUnknown); err != nil {
+					return err
+				}
+			}
+			for level, mode := range addRiskModes {
+				l, err := config.ParseRiskLevel(level)
+				if err != nil {
+					return err
+				}
+				m, err := config.ParseMode(mode)
+				if err != nil {
+					return err
+				}
+				if rule.RiskModes == nil {
+					rule.RiskModes = make(map[config.RiskLevel]config.Mode)
+				}
+				rule.RiskModes[l] = m
+			}
+
 			cfg, err := loadOrCreateConfig()
 			if err != nil {
 				return err
 			}
 
-			if cfg.AddContext(args[0]) {
+			var added bool
+			if isPlainContext(rule) {
+				added = cfg.AddContext(rule.Context)
+			} else {
+				// A plain entry for the same context would match first and
+				// hide the rule's mode, so the rule replaces it.
+				moved := rule.Equal(config.Rule{Context: rule.Context, Mode: rule.Mode, UnknownCommands: rule.UnknownCommands, RiskModes: rule.RiskModes}) &&
+					cfg.RemoveContext(rule.Context)
+				added = cfg.AddRule(rule) || moved
+			}
+
+			if added {
 				if err := config.Save(cfg); err != nil {
 					return err
 				}
-				ui.PrintSuccess("Added: " + args[0])
+				ui.PrintSuccess("Added: " + describeRule(rule))
 			} else {
-				ui.PrintInfo("Context already protected: " + args[0])
+				ui.PrintInfo("Already protected: " + describeRule(rule))
 			}
 			return nil
 		},
-	})
-
-	rootCmd.AddCommand(&cobra.Command{
-		Use:   "remove <context>",
-		Short: "Remove a context from the protected list",
-		Args:  cobra.ExactArgs(1),
+	}
+	addRuleFlags(addCmd, &addRule)
+	addCmd.Flags().StringVar(&addMode, "mode", "", "protection mode: allow, warn, confirm, typed or block")
+	addCmd.Flags().StringVar(&addUnknown, "unknown-commands", "", "mode for unrecognized commands and plugins")
+	addCmd.Flags().StringToStringVar(&addRiskModes, "risk-mode", nil, "mode for a risk level, e.g. critical=typed (repeatable)")
+	rootCmd.AddCommand(addCmd)
+
+	var removeRule config.Rule
+	removeCmd := &cobra.Command{
+		Use:   "remove [context]",
+		Short: "Remove a context, or a cluster/server/CA rule, from the protected list",
+		Args:  cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			rule, err := ruleFromArgs(removeRule, args)
+			if err != nil {
+				return err
+			}
+
 			exists, err := config.Exists()
 			if err != nil {
 				return err
@@ -172,17 +554,24 @@ func runConfigCommand() error {
 				return err
 			}
 
-			if cfg.RemoveContext(args[0]) {
+			removed := isPlainContext(rule) && cfg.RemoveContext(rule.Context)
+			if !removed {
+				removed = cfg.RemoveRule(rule)
+			}
+
+			if removed {
 				if err := config.Save(cfg); err != nil {
 					return err
 				}
-				ui.PrintSuccess("Removed: " + args[0])
+				ui.PrintSuccess("Removed: " + describeRule(rule))
 			} else {
-				ui.PrintInfo("Context not in protected list: " + args[0])
+				ui.PrintInfo("Not in protected list: " + describeRule(rule))
 			}
 			return nil
 		},
-	})
+	}
+	addRuleFlags(removeCmd, &removeRule)
+	rootCmd.AddCommand(removeCmd)
 
 	rootCmd.AddCommand(&cobra.Command{
 		Use:   "path",
@@ -213,22 +602,69 @@ func loadOrCreateConfig() (*config.Config, error) {
 	return &config.Config{ProtectedContexts: []string{}}, nil
 }
 
+// addRuleFlags registers the flags that describe a protection rule.
+func addRuleFlags(cmd *cobra.Command, rule *config.Rule) {
+	cmd.Flags().StringVar(&rule.Cluster, "cluster", "", "match the kubeconfig cluster name (glob)")
+	cmd.Flags().StringVar(&rule.Server, "server", "", "match the API server URL or host (glob)")
+	cmd.Flags().StringVar(&rule.CAFingerprint, "ca-fingerprint", "", "match the SHA-256 fingerprint of the cluster CA")
+	cmd.Flags().StringSliceVarP(&rule.Namespaces, "namespace", "n", nil, "only protect these namespaces (glob, repeatable)")
+}
+
+// ruleFromArgs combines an optional context argument with rule flags.
+func ruleFromArgs(rule config.Rule, args []string) (config.Rule, error) {
+	if len(args) == 1 {
+		rule.Context = args[0]
+	}
+	if rule.IsEmpty() {
+		return rule, fmt.Errorf("specify a context or at least one of --cluster, --server, --ca-fingerprint")
+	}
+	return rule, nil
+}
+
+// isPlainContext reports whether a rule only names a context pattern, and so
+// belongs in protected_contexts rather than rules.
+func isPlainContext(rule config.Rule) bool {
+	return rule.Context != "" && rule.Equal(config.Rule{Context: rule.Context})
+}
+
+// describeRule returns the context pattern for plain entries, or the full rule.
+func describeRule(rule config.Rule) string {
+	if isPlainContext(rule) {
+		return rule.Context
+	}
+	return rule.String()
+}
+
 func printHelp() {
 	help := `kubectl-guard - Protect production clusters from accidental commands
 
 Usage:
   kubectl-guard [kubectl args...]     Run kubectl with protection
   kubectl-guard config <subcommand>   Manage configuration
+  kubectl-guard audit <subcommand>    Read the audit log
+  kubectl-guard explain [--json] <kubectl args...>
+                                      Show how a command would be handled, without running it
   kubectl-guard --version             Print version
   kubectl-guard --help                Print this help
 
 Config subcommands:
   setup       Run the setup wizard
-  list        List protected contexts
+  list        List protected contexts and the contexts they match
   add <ctx>   Add a context to the protected list
+              (--cluster, --server, --ca-fingerprint add a cluster rule;
+               --namespace limits protection to matching namespaces;
+               --mode sets warn, confirm, typed or block;
+               --risk-mode critical=typed sets the mode for a risk level)
   remove <ctx> Remove a context from the protected list
   path        Print the config file path
 
+Audit subcommands:
+  list        List records (--context, --user, --verb, --decision, --since, --until)
+  show <id>   Show one record in full (an unambiguous id prefix is enough)
+  report      Summarize confirmations, aborts, blocks and top verbs per context
+  verify      Check the hash chain and report the first broken or modified record
+              (list, show and report take -o table, json, csv or markdown)
+
 Examples:
   # First run triggers setup wizard
   kubectl-guard get pods
@@ -236,14 +672,43 @@ Examples:
   # Run kubectl commands normally (alias recommended)
   alias kubectl='kubectl-guard'
   kubectl delete pod nginx   # Prompts for confirmation on protected contexts
+  kubectl delete pod nginx --guard-yes --guard-reason INC-123
+
+  # Why did (or didn't) this prompt?
+  kubectl-guard explain delete pods -l app=web
+  kubectl-guard explain --json -- events   # "--" when the command could be a resource name
+
+  # Review what happened on prod this week
+  kubectl-guard audit list --context 'prod-*' --decision aborted --since 7d
+  kubectl-guard audit report --since 2026-10-01 -o markdown
 
   # Manage configuration
   kubectl-guard config list
   kubectl-guard config add prod-*
+  kubectl-guard config add --server '*.prod.example.com'
+  kubectl-guard config add shared-cluster -n 'payments-*' -n kube-system
+  kubectl-guard config add prod-* --mode typed
+  kubectl-guard config add prod-* --risk-mode low=warn --risk-mode critical=typed
   kubectl-guard config remove staging
 
+Guard options (removed before kubectl runs):
+  --guard-yes                   Answer the confirmation prompt with yes (announced on stderr)
+  --guard-reason <text>         Say why the command is run
+  --guard-explain               Print the decision without running kubectl (alias --guard-dry-run)
+  --guard-expect-context <ctx>  Refuse unless the command resolves to a matching context (glob)
+
+Exit codes:
+  1  Denied by the user at the confirmation prompt
+  3  Denied by policy: a context in block mode, or the non-interactive policy
+  4  Confirmation required but no terminal or non-interactive policy is available
+
 Environment:
   Config file: ~/.kubectl-guard.yaml
+  Audit log:   ~/.kubectl-guard/audit.jsonl (see audit: in the config file)
+  KUBECTL_GUARD_ASSUME_YES=1        Answer confirmation prompts with yes (announced on stderr)
+  KUBECTL_GUARD_APPROVAL_TOKEN      Token for the non_interactive: token policy
+  KUBECTL_GUARD_ON_ERROR=block      Mode when the config or kubeconfig can't be read
+                                    (allow, warn, confirm or block; default warn)
 `
 	fmt.Print(strings.TrimSpace(help) + "\n")
 }
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cameronlockhart/kubectl-guard/guard"
+)
+
+func TestMessageRedactsUncheckedCommand(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	cfg := "protected_contexts: [prod]\nredact:\n  flags: [--vault-token]\n"
+	if err := os.WriteFile(filepath.Join(home, ".kubectl-guard.yaml"), []byte(cfg), 0600); err != nil {
+		t.Fatal(err)
+	}
+	// A kubeconfig without a current context can't be resolved.
+	kubeconfig := filepath.Join(home, "kubeconfig")
+	if err := os.WriteFile(kubeconfig, []byte("contexts: []\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("KUBECONFIG", kubeconfig)
+
+	args := []string{"--vault-token", "s3cret", "delete", "pod", "web"}
+	d, err := guard.Check(args)
+	if err == nil {
+		t.Fatal("Check() error = nil, want the context unresolved")
+	}
+	inv := &invocation{args: args, decision: d, checkErr: err}
+	msg := inv.message()
+	if strings.Contains(msg, "s3cret") || !strings.Contains(msg, "could not be checked") {
+		t.Errorf("message() = %q, want the unchecked command without the secret", msg)
+	}
+}
@@ -3,6 +3,7 @@ package ui
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -30,22 +31,184 @@ var (
 
 	dimStyle = lipgloss.NewStyle().
 			Foreground(lipgloss.Color("8"))
+
+	errorStyle = lipgloss.NewStyle().
+			Bold(true).
+			Foreground(lipgloss.Color("9"))
+
+	blastRadiusStyle = lipgloss.NewStyle().
+				Border(lipgloss.ThickBorder()).
+				BorderForeground(lipgloss.Color("9")).
+				Padding(0, 1)
+
+	bannerStyle = lipgloss.NewStyle().
+			Bold(true).
+			Foreground(lipgloss.Color("0")).
+			Background(lipgloss.Color("11")).
+			Padding(0, 1)
 )
 
+// ErrNoTerminal is returned when a prompt needs an answer but the process
+// has no controlling terminal to ask on, e.g. in CI or under cron.
+var ErrNoTerminal = errors.New("no terminal available to confirm")
+
+// ask shows message and prompt on the terminal and returns the answer line.
+// A closed terminal (Ctrl-D) is an empty answer.
+func ask(message, prompt string) (string, error) {
+	tty, err := openTerminal()
+	if err != nil {
+		return "", err
+	}
+	defer tty.Close()
+
+	fmt.Fprint(tty, message+"\n")
+	fmt.Fprint(tty, prompt)
+
+	response, err := bufio.NewReader(tty).ReadString('\n')
+	if err != nil && response == "" {
+		fmt.Fprintln(tty)
+		return "", nil
+	}
+	return strings.TrimSpace(response), nil
+}
+
 // Confirm prompts the user for a yes/no confirmation.
 // Returns true if the user confirms, false otherwise.
-func Confirm(message string) bool {
-	fmt.Print(warningStyle.Render("⚠️  "+message) + "\n")
-	fmt.Print("Confirm? [y/N]: ")
+func Confirm(message string) (bool, error) {
+	response, err := ask(warningStyle.Render("⚠️  "+message), "Confirm? [y/N]: ")
+	if err != nil {
+		return false, err
+	}
 
-	reader := bufio.NewReader(os.Stdin)
-	response, err := reader.ReadString('\n')
+	response = strings.ToLower(response)
+	return response == "y" || response == "yes", nil
+}
+
+// ConfirmTyped prompts the user to type the expected text (such as a context
+// name) to confirm. Returns true only on an exact match.
+func ConfirmTyped(message, expected string) (bool, error) {
+	response, err := ask(warningStyle.Render("⚠️  "+message), fmt.Sprintf("Type %s to confirm: ", selectedStyle.Render(expected)))
 	if err != nil {
-		return false
+		return false, err
 	}
 
-	response = strings.ToLower(strings.TrimSpace(response))
-	return response == "y" || response == "yes"
+	return response == expected, nil
+}
+
+// ConfirmBulk prompts for confirmation of a command that can touch many
+// resources. It shows the blast radius and requires typing "yes" in full.
+func ConfirmBulk(message string, blastRadius []string) (bool, error) {
+	response, err := ask(
+		warningStyle.Render("⚠️  "+message)+"\n"+renderBlastRadius(blastRadius),
+		fmt.Sprintf("Type %s to run this bulk operation: ", selectedStyle.Render("yes")),
+	)
+	if err != nil {
+		return false, err
+	}
+
+	return strings.ToLower(response) == "yes", nil
+}
+
+// PrintBlastRadius prints a boxed list of everything a bulk command affects.
+func PrintBlastRadius(lines []string) {
+	fmt.Fprintln(os.Stderr, renderBlastRadius(lines))
+}
+
+func renderBlastRadius(lines []string) string {
+	body := errorStyle.Render("BULK OPERATION — this command affects:")
+	for _, line := range lines {
+		body += "\n  • " + line
+	}
+	return blastRadiusStyle.Render(body)
+}
+
+// SelectOption is an option in a single-select list.
+type SelectOption struct {
+	Name        string
+	Description string
+}
+
+// selectModel is the bubbletea model for single-select.
+type selectModel struct {
+	title    string
+	options  []SelectOption
+	cursor   int
+	finished bool
+	quitted  bool
+}
+
+func (m selectModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m selectModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c", "q":
+			m.quitted = true
+			return m, tea.Quit
+		case "up", "k":
+			if m.cursor > 0 {
+				m.cursor--
+			}
+		case "down", "j":
+			if m.cursor < len(m.options)-1 {
+				m.cursor++
+			}
+		case "enter", " ":
+			m.finished = true
+			return m, tea.Quit
+		}
+	}
+	return m, nil
+}
+
+func (m selectModel) View() string {
+	if m.finished || m.quitted {
+		return ""
+	}
+
+	var b strings.Builder
+
+	b.WriteString(titleStyle.Render(m.title))
+	b.WriteString("\n\n")
+
+	for i, opt := range m.options {
+		cursor := "  "
+		name := opt.Name
+		if i == m.cursor {
+			cursor = cursorStyle.Render("> ")
+			name = selectedStyle.Render(opt.Name)
+		}
+		b.WriteString(fmt.Sprintf("%s%-8s %s\n", cursor, name, dimStyle.Render(opt.Description)))
+	}
+
+	return b.String()
+}
+
+// Select presents an interactive single-select prompt, starting at the
+// option with index initial. Returns the chosen index and whether the user
+// confirmed (vs quit).
+func Select(title string, options []SelectOption, initial int) (int, bool) {
+	m := selectModel{
+		title:   title,
+		options: options,
+		cursor:  initial,
+	}
+
+	p := tea.NewProgram(m, tea.WithInputTTY())
+	finalModel, err := p.Run()
+	if err != nil {
+		return 0, false
+	}
+
+	result := finalModel.(selectModel)
+	if result.quitted {
+		return 0, false
+	}
+
+	return result.cursor, true
 }
 
 // MultiSelectItem represents an item in the multi-select list.
@@ -153,7 +316,7 @@ func MultiSelect(items []MultiSelectItem) ([]MultiSelectItem, bool) {
 		items: items,
 	}
 
-	p := tea.NewProgram(m)
+	p := tea.NewProgram(m, tea.WithInputTTY())
 	finalModel, err := p.Run()
 	if err != nil {
 		return nil, false
@@ -177,6 +340,16 @@ func PrintWarning(message string) {
 	fmt.Println(warningStyle.Render("⚠️  " + message))
 }
 
+// PrintError prints an error message.
+func PrintError(message string) {
+	fmt.Fprintln(os.Stderr, errorStyle.Render("✗ "+message))
+}
+
+// PrintBanner prints a prominent warning banner.
+func PrintBanner(message string) {
+	fmt.Fprintln(os.Stderr, bannerStyle.Render("⚠️  "+message))
+}
+
 // PrintInfo prints an info message.
 func PrintInfo(message string) {
 	fmt.Println(message)
@@ -0,0 +1,18 @@
+//go:build !windows
+
+package ui
+
+import (
+	"io"
+	"os"
+)
+
+// openTerminal opens the controlling terminal. Prompts use it rather than
+// stdin and stdout, which may be pipes carrying data for kubectl.
+func openTerminal() (io.ReadWriteCloser, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, ErrNoTerminal
+	}
+	return tty, nil
+}
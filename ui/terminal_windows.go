@@ -0,0 +1,36 @@
+package ui
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+// console is the Windows console, whose input and output are separate
+// files.
+type console struct {
+	in, out *os.File
+}
+
+func (c console) Read(p []byte) (int, error)  { return c.in.Read(p) }
+func (c console) Write(p []byte) (int, error) { return c.out.Write(p) }
+
+func (c console) Close() error {
+	return errors.Join(c.in.Close(), c.out.Close())
+}
+
+// openTerminal opens the console the process is attached to. Prompts use it
+// rather than stdin and stdout, which may be pipes carrying data for
+// kubectl.
+func openTerminal() (io.ReadWriteCloser, error) {
+	in, err := os.OpenFile("CONIN$", os.O_RDWR, 0)
+	if err != nil {
+		return nil, ErrNoTerminal
+	}
+	out, err := os.OpenFile("CONOUT$", os.O_RDWR, 0)
+	if err != nil {
+		in.Close()
+		return nil, ErrNoTerminal
+	}
+	return console{in: in, out: out}, nil
+}